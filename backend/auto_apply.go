@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"frp-admin/models"
+)
+
+// ============= 代理/访问变更后自动应用 =============
+
+// 合并短时间内的多次修改，最后一次修改后等待的时间
+const autoApplyDebounce = 3 * time.Second
+
+var autoApplyTimers = struct {
+	sync.Mutex
+	timers map[uint]*time.Timer
+}{timers: make(map[uint]*time.Timer)}
+
+// scheduleAutoApply 在客户端启用自动应用时标记为待应用，并延迟推送
+func scheduleAutoApply(clientID uint, author string) {
+	var client models.FrpcConfig
+	if err := db.First(&client, clientID).Error; err != nil || !client.AutoApply || !client.AdminEnabled {
+		return
+	}
+	db.Model(&client).Updates(map[string]interface{}{"apply_status": "pending", "apply_error": ""})
+
+	autoApplyTimers.Lock()
+	defer autoApplyTimers.Unlock()
+	if t, ok := autoApplyTimers.timers[clientID]; ok {
+		t.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(autoApplyDebounce, func() {
+		autoApplyTimers.Lock()
+		// 已触发但等待锁期间被新的修改取代时，交给新的定时器处理，不能删除它
+		if autoApplyTimers.timers[clientID] != timer {
+			autoApplyTimers.Unlock()
+			return
+		}
+		delete(autoApplyTimers.timers, clientID)
+		autoApplyTimers.Unlock()
+
+		defer lockClientApply(clientID)()
+		runAutoApply(clientID, author)
+	})
+	autoApplyTimers.timers[clientID] = timer
+}
+
+// runAutoApply 推送最新配置，并通过 frpc status 确认代理已启动
+func runAutoApply(clientID uint, author string) {
+	var client models.FrpcConfig
+	if err := db.Preload("Proxies").Preload("Visitors").First(&client, clientID).Error; err != nil {
+		return
+	}
+	if !client.AutoApply || !client.AdminEnabled {
+		return
+	}
+
+	err := applyAndVerify(&client, author)
+	now := time.Now()
+	updates := map[string]interface{}{"apply_status": "applied", "apply_error": "", "applied_at": &now}
+	if err != nil {
+		log.Printf("auto apply: client %s failed: %v", client.Name, err)
+		updates = map[string]interface{}{"apply_status": "failed", "apply_error": err.Error()}
+	}
+	db.Model(&client).Updates(updates)
+}
+
+func applyAndVerify(client *models.FrpcConfig, author string) error {
+	now := time.Now()
+	if reason := clientAccessDenied(client, now); reason != "" {
+		return fmt.Errorf("客户端当前不允许访问: %s", reason)
+	}
+	frpcClient, err := getFrpcClient(client)
+	if err != nil {
+		return err
+	}
+	_, err = applyWithRollback(frpcClient, allowedProxiesClient(client, now), author)
+	return err
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+	_ "time/tzdata" // 内嵌时区数据，系统没有 zoneinfo 时也能解析时区设置
+
+	"frp-admin/models"
+	"frp-admin/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============= 客户端/代理访问控制 =============
+
+// accessTimezoneSetting 访问时间段所用时区的设置项，值为 IANA 时区名（如 Asia/Shanghai），
+// 未设置时使用服务器本地时区
+const accessTimezoneSetting = "access_timezone"
+
+// accessLocation 返回判断访问时间段所用的时区
+func accessLocation() *time.Location {
+	var setting models.Setting
+	if err := db.Where("key = ?", accessTimezoneSetting).First(&setting).Error; err == nil && setting.Value != "" {
+		if loc, err := time.LoadLocation(setting.Value); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// clientAccessDenied 返回客户端当前不允许访问的原因，允许时返回空字符串
+func clientAccessDenied(client *models.FrpcConfig, now time.Time) string {
+	return utils.CheckAccess(client.Disabled, client.ExpiresAt, client.AccessWindows, now.In(accessLocation()))
+}
+
+// proxyAccessDenied 返回代理当前不允许访问的原因，允许时返回空字符串
+func proxyAccessDenied(proxy *models.Proxy, now time.Time) string {
+	return utils.CheckAccess(proxy.Disabled, proxy.ExpiresAt, proxy.AccessWindows, now.In(accessLocation()))
+}
+
+// proxyRemoved 已禁用或已过期的代理不再生成到 frpc 配置中
+func proxyRemoved(proxy *models.Proxy, now time.Time) bool {
+	return proxy.Disabled || (proxy.ExpiresAt != nil && !now.Before(*proxy.ExpiresAt))
+}
+
+// accessRequest 访问控制请求，access_windows 为 AccessWindow 数组的 JSON，
+// 星期和时刻按系统设置 access_timezone 指定的时区判断
+type accessRequest struct {
+	Disabled      bool       `json:"disabled"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	AccessWindows string     `json:"access_windows"`
+}
+
+func (r *accessRequest) updates() map[string]interface{} {
+	return map[string]interface{}{
+		"disabled":       r.Disabled,
+		"expires_at":     r.ExpiresAt,
+		"access_windows": r.AccessWindows,
+	}
+}
+
+// 修改客户端的访问控制，立即失去访问权限时停止在线的 frpc
+func updateClientAccessHandler(c *gin.Context) {
+	id := c.Param("id")
+	var client models.FrpcConfig
+	if err := db.First(&client, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	var req accessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if _, err := utils.ParseAccessWindows(req.AccessWindows); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db.Model(&client).Updates(req.updates())
+	db.First(&client, id)
+
+	result := gin.H{"client": client}
+	if reason := clientAccessDenied(&client, time.Now()); reason != "" {
+		result["denied"] = reason
+		if client.AdminEnabled {
+			if err := stopDeniedClient(&client); err != nil {
+				result["stop_error"] = err.Error()
+			} else {
+				result["stopped"] = true
+			}
+		}
+	}
+	accessEnforcer.forget(client.ID)
+	c.JSON(http.StatusOK, result)
+}
+
+// 修改代理的访问控制，在线管理的客户端会立即推送新配置
+func updateProxyAccessHandler(c *gin.Context) {
+	id := c.Param("id")
+	var proxy models.Proxy
+	if err := db.First(&proxy, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Proxy not found"})
+		return
+	}
+
+	var req accessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if _, err := utils.ParseAccessWindows(req.AccessWindows); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db.Model(&proxy).Updates(req.updates())
+	db.First(&proxy, id)
+
+	result := gin.H{"proxy": proxy}
+	var client models.FrpcConfig
+	if err := db.Preload("Proxies").Preload("Visitors").First(&client, proxy.FrpcConfigID).Error; err == nil && client.AdminEnabled {
+		if err := pushAllowedProxies(&client, time.Now(), c.GetString("username")); err != nil {
+			result["push_error"] = err.Error()
+		} else {
+			result["pushed"] = true
+		}
+	}
+	accessEnforcer.forget(proxy.FrpcConfigID)
+	c.JSON(http.StatusOK, result)
+}
+
+func stopDeniedClient(client *models.FrpcConfig) error {
+	frpcClient, err := getFrpcClient(client)
+	if err != nil {
+		return err
+	}
+	defer lockClientApply(client.ID)()
+	return frpcClient.Stop()
+}
+
+// pushAllowedProxies 推送只包含当前允许访问的代理的配置，用于在访问时间段外下线代理，
+// 批量推送和 token 轮换也通过它推送
+func pushAllowedProxies(client *models.FrpcConfig, now time.Time, author string) error {
+	frpcClient, err := getFrpcClient(client)
+	if err != nil {
+		return err
+	}
+	defer lockClientApply(client.ID)()
+	return pushClientConfig(frpcClient, allowedProxiesClient(client, now), author)
+}
+
+// allowedProxiesClient 返回只保留当前允许访问的代理的客户端副本
+func allowedProxiesClient(client *models.FrpcConfig, now time.Time) *models.FrpcConfig {
+	filtered := *client
+	filtered.Proxies = nil
+	for _, p := range client.Proxies {
+		if proxyAccessDenied(&p, now) == "" {
+			filtered.Proxies = append(filtered.Proxies, p)
+		}
+	}
+	return &filtered
+}
+
+// accessState 记录上一次检查时的访问状态，状态变化时才执行操作
+type accessState struct {
+	clientDenied bool
+	proxyDenied  map[uint]bool
+}
+
+type accessEnforcerState struct {
+	mu     sync.Mutex
+	states map[uint]*accessState
+}
+
+var accessEnforcer = &accessEnforcerState{states: make(map[uint]*accessState)}
+
+// forget 清除客户端的状态，下次检查时重新判断
+func (e *accessEnforcerState) forget(clientID uint) {
+	e.mu.Lock()
+	delete(e.states, clientID)
+	e.mu.Unlock()
+}
+
+// runAccessEnforcer 每分钟检查一次过期时间和访问时间段：
+// 客户端失去访问权限时停止 frpc，代理的访问状态变化时重新推送配置。
+// 新的登录和代理注册由 frps 插件拦截
+func runAccessEnforcer() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		accessEnforcer.check(time.Now())
+	}
+}
+
+func (e *accessEnforcerState) check(now time.Time) {
+	var clients []models.FrpcConfig
+	db.Preload("Proxies").Preload("Visitors").Find(&clients)
+
+	// 在锁内只更新状态，停止 frpc 和推送配置可能很慢，放到锁外执行，避免阻塞 forget
+	var stops, pushes []*models.FrpcConfig
+	e.mu.Lock()
+	for i := range clients {
+		client := &clients[i]
+		prev, known := e.states[client.ID]
+		state := &accessState{proxyDenied: make(map[uint]bool)}
+		state.clientDenied = clientAccessDenied(client, now) != ""
+		proxyChanged := false
+		for _, p := range client.Proxies {
+			denied := proxyAccessDenied(&p, now) != ""
+			state.proxyDenied[p.ID] = denied
+			if known && prev.proxyDenied[p.ID] != denied {
+				proxyChanged = true
+			}
+		}
+		e.states[client.ID] = state
+
+		if !known || !client.AdminEnabled {
+			continue
+		}
+		if state.clientDenied {
+			if !prev.clientDenied {
+				stops = append(stops, client)
+			}
+			continue
+		}
+		if proxyChanged {
+			pushes = append(pushes, client)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, client := range stops {
+		if err := stopDeniedClient(client); err != nil {
+			log.Printf("access enforcer: stop client %s failed: %v", client.Name, err)
+		} else {
+			log.Printf("access enforcer: client %s stopped", client.Name)
+		}
+	}
+	for _, client := range pushes {
+		if err := pushAllowedProxies(client, now, "system"); err != nil {
+			log.Printf("access enforcer: push config to client %s failed: %v", client.Name, err)
+		}
+	}
+}
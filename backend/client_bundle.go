@@ -0,0 +1,432 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"frp-admin/config"
+	"frp-admin/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// ============= 客户端安装包 Handler =============
+
+// 安装包下载地址的有效期
+const bundleURLTTL = 15 * time.Minute
+
+var (
+	bundleOSes    = map[string]bool{"linux": true, "darwin": true, "freebsd": true, "windows": true}
+	bundleArches  = map[string]bool{"amd64": true, "arm64": true, "arm": true, "386": true, "mips": true, "mipsle": true, "mips64": true, "mips64le": true, "riscv64": true, "loong64": true}
+	unsafeNameRe  = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+	bundleFormats = map[string]bool{"tar.gz": true, "zip": true}
+)
+
+// bundleClaims 签名地址中携带的安装包参数
+type bundleClaims struct {
+	ClientID uint   `json:"c"`
+	Version  string `json:"v"`
+	OS       string `json:"o"`
+	Arch     string `json:"a"`
+	Format   string `json:"f"`
+	Author   string `json:"u"`
+	Expires  int64  `json:"e"`
+}
+
+// bundleKey 缓存的签名密钥，避免每次签名和校验都读取数据库
+var bundleKey struct {
+	sync.Mutex
+	value []byte
+}
+
+// bundleSigningKey 安装包地址的签名密钥，首次使用时生成并保存，重启后已签发的地址仍然有效。
+// 只在不存在时插入并重新读取，并发的首次调用得到的是同一个密钥
+func bundleSigningKey() ([]byte, error) {
+	bundleKey.Lock()
+	defer bundleKey.Unlock()
+	if bundleKey.value != nil {
+		return bundleKey.value, nil
+	}
+
+	db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Setting{Key: "bundle_signing_key", Value: config.GenerateRandomToken()})
+	var setting models.Setting
+	if err := db.Where("key = ?", "bundle_signing_key").First(&setting).Error; err != nil {
+		return nil, fmt.Errorf("load bundle signing key failed: %v", err)
+	}
+	if setting.Value == "" {
+		return nil, fmt.Errorf("bundle signing key is empty")
+	}
+	bundleKey.value = []byte(setting.Value)
+	return bundleKey.value, nil
+}
+
+func bundleSignature(encoded string) (string, error) {
+	key, err := bundleSigningKey()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(encoded))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func signBundleClaims(claims *bundleClaims) (string, error) {
+	payload, _ := json.Marshal(claims)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := bundleSignature(encoded)
+	if err != nil {
+		return "", err
+	}
+	return encoded + "." + sig, nil
+}
+
+func parseBundleToken(token string) (*bundleClaims, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, fmt.Errorf("invalid token")
+	}
+	expected, err := bundleSignature(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return nil, fmt.Errorf("invalid token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token")
+	}
+	var claims bundleClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("invalid token")
+	}
+	if time.Now().Unix() > claims.Expires {
+		return nil, fmt.Errorf("download link expired")
+	}
+	return &claims, nil
+}
+
+// createClientBundleHandler 生成客户端安装包的签名下载地址
+// 请求体 {"os": "linux", "arch": "amd64", "format": "tar.gz", "version": "0.61.0"}，format 和 version 可省略
+func createClientBundleHandler(c *gin.Context) {
+	var req struct {
+		OS      string `json:"os" binding:"required"`
+		Arch    string `json:"arch" binding:"required"`
+		Format  string `json:"format"`
+		Version string `json:"version"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if !bundleOSes[req.OS] || !bundleArches[req.Arch] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("不支持的平台: %s/%s", req.OS, req.Arch)})
+		return
+	}
+	if req.Format == "" {
+		req.Format = "tar.gz"
+		if req.OS == "windows" {
+			req.Format = "zip"
+		}
+	}
+	if !bundleFormats[req.Format] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 只支持 tar.gz 或 zip"})
+		return
+	}
+	if req.Version == "" {
+		req.Version = frpBinaries.ActiveVersion()
+		if req.Version == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "没有正在使用的 frp 版本，请指定 version"})
+			return
+		}
+	}
+
+	var client models.FrpcConfig
+	if err := db.First(&client, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+	// 提前检查二进制，避免签发无法下载的地址
+	if _, _, err := frpBinaries.ClientBinary(req.Version, req.OS, req.Arch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expires := time.Now().Add(bundleURLTTL)
+	token, err := signBundleClaims(&bundleClaims{
+		ClientID: client.ID,
+		Version:  req.Version,
+		OS:       req.OS,
+		Arch:     req.Arch,
+		Format:   req.Format,
+		Author:   c.GetString("username"),
+		Expires:  expires.Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	path := "/api/bundles/" + token
+	filename := bundleFilename(&client, req.Version, req.OS, req.Arch, req.Format)
+	url := requestBaseURL(c) + path
+	c.JSON(http.StatusOK, gin.H{
+		"url":        url,
+		"path":       path,
+		"filename":   filename,
+		"expires_at": expires,
+		"curl":       fmt.Sprintf("curl -fL -o %s '%s'", filename, url),
+	})
+}
+
+// requestBaseURL 根据请求（包括反向代理头）还原面板的访问地址
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	host := c.Request.Host
+	if forwarded := c.GetHeader("X-Forwarded-Host"); forwarded != "" {
+		host = forwarded
+	}
+	return scheme + "://" + host
+}
+
+func bundleFilename(client *models.FrpcConfig, version, goos, goarch, format string) string {
+	return fmt.Sprintf("frpc_%s_%s_%s_%s.%s", unsafeNameRe.ReplaceAllString(client.User, "_"), version, goos, goarch, format)
+}
+
+// downloadClientBundleHandler 通过签名地址下载安装包，不需要登录
+func downloadClientBundleHandler(c *gin.Context) {
+	claims, err := parseBundleToken(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var client models.FrpcConfig
+	if err := db.Preload("Proxies").Preload("Visitors").First(&client, claims.ClientID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+	binaryPath, binaryName, err := frpBinaries.ClientBinary(claims.Version, claims.OS, claims.Arch)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	content, err := generateClientToml(&client)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成配置失败: " + err.Error()})
+		return
+	}
+	recordFrpcRevision(&client, content, "download", claims.Author)
+
+	files := clientBundleFiles(&client, content, binaryName, claims.OS)
+	filename := bundleFilename(&client, claims.Version, claims.OS, claims.Arch, claims.Format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	if claims.Format == "zip" {
+		c.Header("Content-Type", "application/zip")
+		err = writeBundleZip(c.Writer, binaryPath, binaryName, files)
+	} else {
+		c.Header("Content-Type", "application/gzip")
+		err = writeBundleTarGz(c.Writer, binaryPath, binaryName, files)
+	}
+	if err != nil {
+		// 响应已经开始写入，只能中断连接
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+// bundleFile 安装包中除 frpc 以外的文件
+type bundleFile struct {
+	name       string
+	content    string
+	executable bool
+}
+
+// clientBundleFiles 生成配置文件和对应系统的服务安装脚本
+func clientBundleFiles(client *models.FrpcConfig, content, binaryName, goos string) []bundleFile {
+	user := unsafeNameRe.ReplaceAllString(client.User, "_")
+	tomlName := fmt.Sprintf("frpc_%s.toml", user)
+	files := []bundleFile{{name: tomlName, content: content}}
+
+	if goos == "windows" {
+		files = append(files, bundleFile{name: "install-service.ps1", content: fmt.Sprintf(windowsInstallScript, user, binaryName, tomlName)})
+		return files
+	}
+	service := "frpc-" + user
+	files = append(files,
+		bundleFile{name: service + ".service", content: fmt.Sprintf(systemdUnitTemplate, unitDescription(client.Name), tomlName)},
+		bundleFile{name: "install.sh", content: fmt.Sprintf(unixInstallScript, service, tomlName), executable: true},
+	)
+	return files
+}
+
+// unitDescription 去掉控制字符（包括换行），避免客户端名称在 unit 中注入其他指令；
+// 行尾的反斜杠会续行，systemd 还会展开 % 说明符，同样需要处理
+func unitDescription(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, `\`)
+	return strings.ReplaceAll(name, "%", "%%")
+}
+
+const systemdUnitTemplate = `[Unit]
+Description=frp client (%s)
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart=/usr/local/bin/frpc -c /etc/frp/%s
+Restart=on-failure
+RestartSec=5s
+
+[Install]
+WantedBy=multi-user.target
+`
+
+const unixInstallScript = `#!/bin/sh
+# 安装 frpc 和配置文件，有 systemd 时注册并启动服务
+set -e
+cd "$(dirname "$0")"
+SERVICE=%s
+CONFIG=%s
+
+install -m 0755 frpc /usr/local/bin/frpc
+mkdir -p /etc/frp
+install -m 0600 "$CONFIG" /etc/frp/"$CONFIG"
+
+if command -v systemctl >/dev/null 2>&1; then
+	install -m 0644 "$SERVICE.service" /etc/systemd/system/"$SERVICE.service"
+	systemctl daemon-reload
+	systemctl enable --now "$SERVICE"
+	echo "frpc installed and started as $SERVICE"
+else
+	echo "systemd not found, run: /usr/local/bin/frpc -c /etc/frp/$CONFIG"
+fi
+`
+
+const windowsInstallScript = `# 以管理员身份运行：将 frpc 安装到 Program Files 并注册为 Windows 服务
+$ErrorActionPreference = "Stop"
+$Service = "frpc-%s"
+$Dir = Join-Path $env:ProgramFiles "frpc"
+New-Item -ItemType Directory -Force -Path $Dir | Out-Null
+Copy-Item -Force (Join-Path $PSScriptRoot "%s") (Join-Path $Dir "frpc.exe")
+Copy-Item -Force (Join-Path $PSScriptRoot "%s") (Join-Path $Dir "frpc.toml")
+
+if (Get-Service -Name $Service -ErrorAction SilentlyContinue) {
+    Stop-Service -Name $Service -ErrorAction SilentlyContinue
+    sc.exe delete $Service | Out-Null
+}
+$BinPath = '"' + (Join-Path $Dir "frpc.exe") + '" -c "' + (Join-Path $Dir "frpc.toml") + '"'
+New-Service -Name $Service -BinaryPathName $BinPath -StartupType Automatic -DisplayName $Service | Out-Null
+sc.exe failure $Service reset= 0 actions= restart/5000 | Out-Null
+Start-Service -Name $Service
+Write-Host "frpc installed and started as $Service"
+`
+
+func writeBundleTarGz(w io.Writer, binaryPath, binaryName string, files []bundleFile) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	if err := addBinaryToTar(tw, binaryPath, binaryName, now); err != nil {
+		return err
+	}
+	for _, f := range files {
+		mode := int64(0644)
+		if f.executable {
+			mode = 0755
+		}
+		hdr := &tar.Header{Name: f.name, Mode: mode, Size: int64(len(f.content)), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(tw, f.content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addBinaryToTar(tw *tar.Writer, path, name string, modTime time.Time) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: name, Mode: 0755, Size: info.Size(), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func writeBundleZip(w io.Writer, binaryPath, binaryName string, files []bundleFile) error {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+
+	f, err := os.Open(binaryPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hdr := &zip.FileHeader{Name: binaryName, Method: zip.Deflate, Modified: now}
+	hdr.SetMode(0755)
+	entry, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(entry, f); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		hdr := &zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: now}
+		if file.executable {
+			hdr.SetMode(0755)
+		} else {
+			hdr.SetMode(0644)
+		}
+		entry, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(entry, file.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"os"
 	"path/filepath"
+	"strconv"
 )
 
 type Config struct {
@@ -14,27 +15,47 @@ type Config struct {
 	FrpsPath    string
 	FrpsConfig  string
 	FrpcPath    string
-	// frps 管理方式: "process"(直接进程) 或 "systemctl"(通过systemctl)
+	// frps 管理方式: "process"(直接进程)、"systemctl"(通过systemctl) 或 "docker"(通过 Docker Engine API)
 	FrpsManager string
-	// systemctl 模式下的 service 名称
+	// systemctl 模式下的 service 名称，docker 模式下的容器名称
 	FrpsService string
+	// docker 模式下 Docker Engine 的 unix socket 路径
+	DockerSocket string
 	// CORS 允许的来源，多个用逗号分隔，默认空表示仅同源
 	CorsOrigins string
+	// 进程模式下保存 frps 输出的目录（按大小轮转）
+	FrpsLogDir string
+	// 进程模式下 frps 意外退出后自动重启
+	FrpsSupervise bool
+	// 进程模式下 frps 在独立进程组运行，frp-admin 重启后通过 PID 文件接管
+	FrpsDetach  bool
+	FrpsPIDFile string
+	// 停止 frps 时等待退出的秒数，超时后发送 SIGKILL
+	FrpsStopTimeout int
+	// 托管的 frps/frpc 多版本目录，激活版本后优先于 FrpsPath/FrpcPath
+	FrpBinDir string
 }
 
 var AppConfig *Config
 
 func Init() {
 	AppConfig = &Config{
-		Port:        getEnv("FRP_ADMIN_PORT", "8080"),
-		DBPath:      getEnv("FRP_ADMIN_DB", "frp_admin.db"),
-		JWTSecret:   getEnv("FRP_ADMIN_SECRET", generateSecret()),
-		FrpsPath:    getEnv("FRP_ADMIN_FRPS_PATH", getFrpsPath()),
-		FrpsConfig:  getEnv("FRP_ADMIN_FRPS_CONFIG", getFrpsConfigPath()),
-		FrpcPath:    getEnv("FRP_ADMIN_FRPC_PATH", getFrpcPath()),
-		FrpsManager: getEnv("FRP_ADMIN_FRPS_MANAGER", "process"), // process 或 systemctl
-		FrpsService: getEnv("FRP_ADMIN_FRPS_SERVICE", "frps"),    // systemctl 模式下的服务名
-		CorsOrigins: getEnv("FRP_ADMIN_CORS_ORIGINS", ""),        // CORS 允许的来源，空表示仅同源
+		Port:            getEnv("FRP_ADMIN_PORT", "8080"),
+		DBPath:          getEnv("FRP_ADMIN_DB", "frp_admin.db"),
+		JWTSecret:       getEnv("FRP_ADMIN_SECRET", generateSecret()),
+		FrpsPath:        getEnv("FRP_ADMIN_FRPS_PATH", getFrpsPath()),
+		FrpsConfig:      getEnv("FRP_ADMIN_FRPS_CONFIG", getFrpsConfigPath()),
+		FrpcPath:        getEnv("FRP_ADMIN_FRPC_PATH", getFrpcPath()),
+		FrpsManager:     getEnv("FRP_ADMIN_FRPS_MANAGER", "process"), // process、systemctl 或 docker
+		FrpsService:     getEnv("FRP_ADMIN_FRPS_SERVICE", "frps"),    // systemctl 模式下的服务名或 docker 模式下的容器名
+		CorsOrigins:     getEnv("FRP_ADMIN_CORS_ORIGINS", ""),        // CORS 允许的来源，空表示仅同源
+		DockerSocket:    getEnv("FRP_ADMIN_DOCKER_SOCKET", "/var/run/docker.sock"),
+		FrpsLogDir:      getEnv("FRP_ADMIN_FRPS_LOG_DIR", getFrpsLogDir()),
+		FrpsSupervise:   getEnv("FRP_ADMIN_FRPS_SUPERVISE", "false") == "true",
+		FrpsDetach:      getEnv("FRP_ADMIN_FRPS_DETACH", "false") == "true",
+		FrpsPIDFile:     getEnv("FRP_ADMIN_FRPS_PID_FILE", getFrpsPIDFile()),
+		FrpsStopTimeout: getEnvInt("FRP_ADMIN_FRPS_STOP_TIMEOUT", 10),
+		FrpBinDir:       getEnv("FRP_ADMIN_FRP_BIN_DIR", getFrpBinDir()),
 	}
 }
 
@@ -45,6 +66,13 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
 func generateSecret() string {
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
@@ -66,6 +94,15 @@ func GenerateRandomPassword() string {
 	return string(bytes)
 }
 
+// GenerateRandomToken 生成 32 位十六进制随机字符串（用于客户端密钥等）
+func GenerateRandomToken() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		panic("failed to generate random token: " + err.Error())
+	}
+	return hex.EncodeToString(bytes)
+}
+
 func getFrpsPath() string {
 	exe, _ := os.Executable()
 	dir := filepath.Dir(exe)
@@ -83,3 +120,21 @@ func getFrpsConfigPath() string {
 	dir := filepath.Dir(exe)
 	return filepath.Join(dir, "frps.toml")
 }
+
+func getFrpsLogDir() string {
+	exe, _ := os.Executable()
+	dir := filepath.Dir(exe)
+	return filepath.Join(dir, "logs")
+}
+
+func getFrpsPIDFile() string {
+	exe, _ := os.Executable()
+	dir := filepath.Dir(exe)
+	return filepath.Join(dir, "frps.pid")
+}
+
+func getFrpBinDir() string {
+	exe, _ := os.Executable()
+	dir := filepath.Dir(exe)
+	return filepath.Join(dir, "frp-versions")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"frp-admin/config"
+	"frp-admin/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============= frp 可执行文件版本管理 Handler =============
+
+const (
+	// 上传的 release 压缩包大小上限
+	maxFrpArchiveSize = 128 << 20
+	// 切换版本重启后，frps 需要保持运行且 PID 不变的时间，期间退出视为启动失败
+	frpSwitchStableTime   = 3 * time.Second
+	frpSwitchPollInterval = 200 * time.Millisecond
+)
+
+var frpBinaries *utils.FrpBinaryStore
+
+// 版本切换涉及验证、切换链接和重启，同一时间只允许一个
+var frpSwitchMu sync.Mutex
+
+// initFrpBinaries 初始化版本目录，已激活托管版本时优先使用其中的 frps/frpc
+func initFrpBinaries() {
+	frpBinaries = utils.NewFrpBinaryStore(config.AppConfig.FrpBinDir)
+	if path := frpBinaries.ActivePath("frps"); path != "" {
+		config.AppConfig.FrpsPath = path
+	}
+	if path := frpBinaries.ActivePath("frpc"); path != "" {
+		config.AppConfig.FrpcPath = path
+	}
+}
+
+func getFrpVersionsHandler(c *gin.Context) {
+	versions, err := frpBinaries.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	result := gin.H{
+		"versions":  versions,
+		"active":    frpBinaries.ActiveVersion(),
+		"frps_path": config.AppConfig.FrpsPath,
+	}
+	// 以实际使用的 frps 输出为准，未托管的可执行文件同样可以显示版本
+	if reported, err := utils.FrpBinaryVersion(config.AppConfig.FrpsPath); err != nil {
+		result["reported_error"] = err.Error()
+	} else {
+		result["reported_version"] = reported
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// uploadFrpVersionHandler 导入 frp release 压缩包，表单字段 file，可选 sha256 校验压缩包
+func uploadFrpVersionHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFrpArchiveSize)
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传 frp release 压缩包"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	version, err := frpBinaries.Import(header.Filename, file, c.PostForm("sha256"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("frp %s imported from %s by %s", version.Version, version.Source, c.GetString("username"))
+	c.JSON(http.StatusOK, version)
+}
+
+// activateFrpVersionHandler 用新版本验证当前配置后切换，frps 运行中时重启，重启失败则切回原版本
+func activateFrpVersionHandler(c *gin.Context) {
+	version := c.Param("version")
+
+	frpSwitchMu.Lock()
+	defer frpSwitchMu.Unlock()
+
+	if err := frpBinaries.Verify(version); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	manager := utils.GetFrpsManager()
+	candidate := frpBinaries.VersionPath(version, "frps")
+	reported, err := utils.FrpBinaryVersion(candidate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("frps %s 无法运行: %v", version, err)})
+		return
+	}
+	// 先用新版本的 frps 验证当前配置，避免切换后 frps 因配置不兼容无法启动
+	if err := utils.VerifyFrpsConfig(candidate, config.AppConfig.FrpsConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previousFrps, previousFrpc := config.AppConfig.FrpsPath, config.AppConfig.FrpcPath
+	previous, err := frpBinaries.Activate(version)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	setFrpBinaryPaths(frpBinaries.ActivePath("frps"), frpBinaries.ActivePath("frpc"))
+	log.Printf("frp version switched from %q to %s by %s", previous, version, c.GetString("username"))
+
+	result := gin.H{"message": fmt.Sprintf("已切换到 frp %s", version), "version": version, "reported_version": reported, "previous": previous}
+	// 这两种模式下运行的 frps 不由本地路径决定，只切换托管版本（frpc、安装包等），不重启
+	switch manager.GetManagerType() {
+	case "docker":
+		result["message"] = fmt.Sprintf("已切换托管版本到 frp %s", version)
+		result["warning"] = "docker 模式下 frps 来自容器镜像，运行中的 frps 版本未改变"
+		c.JSON(http.StatusOK, result)
+		return
+	case "systemctl":
+		result["message"] = fmt.Sprintf("已切换托管版本到 frp %s", version)
+		result["warning"] = fmt.Sprintf("systemctl 模式下服务运行的 frps 由 unit 的 ExecStart 决定，未自动重启；ExecStart 指向 %s 时手动重启服务后生效", frpBinaries.ActivePath("frps"))
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	if !manager.Status().Running {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	restart, err := manager.Restart()
+	if err == nil {
+		// Restart 在进程启动后即返回，新版本启动后立即退出也会被视为成功，需要观察一段时间
+		err = waitFrpsStable(manager, restart.NewPID, frpSwitchStableTime)
+	}
+	if err == nil {
+		result["restart"] = restart
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	// 新版本启动失败，恢复原来的可执行文件并重新启动
+	result["error"] = fmt.Sprintf("frp %s 启动失败: %v", version, err)
+	var rollbackErr error
+	if previous != "" {
+		_, rollbackErr = frpBinaries.Activate(previous)
+	} else {
+		rollbackErr = frpBinaries.Deactivate()
+	}
+	if rollbackErr == nil {
+		setFrpBinaryPaths(previousFrps, previousFrpc)
+		_, rollbackErr = manager.Restart()
+	}
+	if rollbackErr != nil {
+		result["rollback_error"] = rollbackErr.Error()
+	} else {
+		result["rolled_back"] = true
+	}
+	c.JSON(http.StatusInternalServerError, result)
+}
+
+// waitFrpsStable 确认 frps 在 d 时间内一直运行且没有被守护重启（PID 不变）
+func waitFrpsStable(manager utils.FrpsManagerInterface, pid int, d time.Duration) error {
+	deadline := time.Now().Add(d)
+	for {
+		status := manager.Status()
+		if !status.Running {
+			return fmt.Errorf("frps 启动后退出")
+		}
+		if pid != 0 && status.PID != pid {
+			return fmt.Errorf("frps 启动后退出并被重新拉起（PID %d -> %d）", pid, status.PID)
+		}
+		if time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(frpSwitchPollInterval)
+	}
+}
+
+// setFrpBinaryPaths 更新配置中的路径并通知 frps 管理器，路径为空（版本中没有该文件）时保持不变
+func setFrpBinaryPaths(frpsPath, frpcPath string) {
+	if frpsPath != "" {
+		config.AppConfig.FrpsPath = frpsPath
+		utils.GetFrpsManager().SetFrpsPath(frpsPath)
+	}
+	if frpcPath != "" {
+		config.AppConfig.FrpcPath = frpcPath
+	}
+}
+
+func deleteFrpVersionHandler(c *gin.Context) {
+	frpSwitchMu.Lock()
+	defer frpSwitchMu.Unlock()
+
+	if err := frpBinaries.Delete(c.Param("version")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "版本已删除"})
+}
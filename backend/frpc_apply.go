@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"frp-admin/models"
+	"frp-admin/utils"
+)
+
+// ============= 推送配置并在失败时回滚 =============
+
+const (
+	// 推送后等待代理启动的最长时间
+	applyVerifyTimeout  = 15 * time.Second
+	applyVerifyInterval = time.Second
+	// 自动生成的管理代理，丢失后将无法再远程管理 frpc
+	frpcAdminProxyName = "frpc-admin"
+)
+
+// clientApplyLocks 每个客户端的推送锁，手动重载、批量推送、自动应用和访问控制共用，
+// 避免同一个 frpc 上的推送、回滚和停止交错执行
+var clientApplyLocks = struct {
+	sync.Mutex
+	locks map[uint]*sync.Mutex
+}{locks: make(map[uint]*sync.Mutex)}
+
+// lockClientApply 获取客户端的推送锁，返回解锁函数，用法为 defer lockClientApply(id)()
+func lockClientApply(clientID uint) func() {
+	clientApplyLocks.Lock()
+	lock, ok := clientApplyLocks.locks[clientID]
+	if !ok {
+		lock = &sync.Mutex{}
+		clientApplyLocks.locks[clientID] = lock
+	}
+	clientApplyLocks.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// applyRollbackResult 推送失败时的回滚情况
+type applyRollbackResult struct {
+	RolledBack bool   `json:"rolled_back"`
+	Reason     string `json:"reason"`
+	Error      string `json:"error,omitempty"` // 回滚本身失败的原因
+}
+
+// applyWithRollback 推送前保存 frpc 当前配置，推送后检查管理代理和所有代理是否进入 running，
+// 重载失败或超时未启动时推送回之前的配置。client 应为 allowedProxiesClient 过滤后的副本，
+// 返回的 result 仅在发生回滚时不为 nil
+func applyWithRollback(frpcClient *utils.FrpcClient, client *models.FrpcConfig, author string) (*applyRollbackResult, error) {
+	tomlContent, err := generateClientToml(client)
+	if err != nil {
+		return nil, fmt.Errorf("生成配置失败: %v", err)
+	}
+	previous, err := frpcClient.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("获取当前配置失败，未推送: %v", err)
+	}
+
+	applyErr := pushTomlConfig(frpcClient, client, tomlContent, "push", author)
+	if applyErr == nil {
+		applyErr = verifyProxiesRunning(frpcClient, expectedProxyNames(client))
+	}
+	if applyErr == nil {
+		return nil, nil
+	}
+
+	result := &applyRollbackResult{Reason: applyErr.Error()}
+	if err := pushTomlConfig(frpcClient, client, previous, "rollback", author); err != nil {
+		result.Error = err.Error()
+		return result, fmt.Errorf("%v；回滚失败: %v", applyErr, err)
+	}
+	result.RolledBack = true
+	return result, fmt.Errorf("%v；已回滚到之前的配置", applyErr)
+}
+
+// frpProxyName 返回代理在 frpc status 和 frps dashboard 中的名称，
+// 客户端设置了 user 时 frp 会给代理名称加上 "user." 前缀
+func frpProxyName(client *models.FrpcConfig, name string) string {
+	if client.User == "" {
+		return name
+	}
+	return client.User + "." + name
+}
+
+// expectedProxyNames 返回推送的配置中应当启动的代理名称（frp 运行时名称），
+// client 为实际推送的副本，已由 allowedProxiesClient 去掉当前不允许访问的代理
+func expectedProxyNames(client *models.FrpcConfig) []string {
+	var names []string
+	if client.AdminEnabled {
+		names = append(names, frpProxyName(client, frpcAdminProxyName))
+	}
+	for _, p := range client.Proxies {
+		names = append(names, frpProxyName(client, p.Name))
+	}
+	return names
+}
+
+// verifyProxiesRunning 轮询 frpc status，直到期望的代理都处于 running 状态或超时
+func verifyProxiesRunning(frpcClient *utils.FrpcClient, expected []string) error {
+	if len(expected) == 0 {
+		return nil
+	}
+
+	var problems []string
+	deadline := time.Now().Add(applyVerifyTimeout)
+	for {
+		problems = problems[:0]
+		status, err := frpcClient.GetStatus()
+		if err != nil {
+			problems = append(problems, "获取状态失败: "+err.Error())
+		} else {
+			states := make(map[string]string)
+			for _, s := range status.All() {
+				if s.Status == "running" {
+					states[s.Name] = ""
+				} else {
+					states[s.Name] = s.Status + " " + s.Err
+				}
+			}
+			for _, name := range expected {
+				state, ok := states[name]
+				switch {
+				case !ok:
+					problems = append(problems, name+": 未启动")
+				case state != "":
+					problems = append(problems, name+": "+strings.TrimSpace(state))
+				}
+			}
+		}
+		if len(problems) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			sort.Strings(problems)
+			return fmt.Errorf("代理未全部启动: %s", strings.Join(problems, "; "))
+		}
+		time.Sleep(applyVerifyInterval)
+	}
+}
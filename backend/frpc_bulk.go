@@ -0,0 +1,231 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"frp-admin/config"
+	"frp-admin/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============= 批量推送 frpc 配置 =============
+
+const (
+	defaultBulkPushConcurrency = 4
+	maxBulkPushConcurrency     = 16
+	// 已结束的后台任务保留时长，超过后在创建新任务时清理
+	bulkJobRetention = time.Hour
+)
+
+// bulkPushResult 单个客户端的推送结果，status 为 success、failed 或 skipped
+type bulkPushResult struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// bulkPushClient 生成并推送单个客户端的配置，未启用管理接口或当前不允许访问的客户端跳过
+func bulkPushClient(client *models.FrpcConfig, now time.Time, author string) bulkPushResult {
+	result := bulkPushResult{ID: client.ID, Name: client.Name}
+	if !client.AdminEnabled {
+		result.Status = "skipped"
+		result.Error = "未启用管理接口，需要重新下载配置"
+		return result
+	}
+	if reason := clientAccessDenied(client, now); reason != "" {
+		result.Status = "skipped"
+		result.Error = "客户端当前不允许访问: " + reason
+		return result
+	}
+
+	start := time.Now()
+	err := pushAllowedProxies(client, now, author)
+	result.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	} else {
+		result.Status = "success"
+	}
+	return result
+}
+
+// runBulkPush 使用固定数量的 worker 并发推送，每完成一个客户端调用一次 onResult
+func runBulkPush(clients []models.FrpcConfig, concurrency int, author string, onResult func(bulkPushResult)) {
+	if concurrency <= 0 {
+		concurrency = defaultBulkPushConcurrency
+	}
+	if concurrency > maxBulkPushConcurrency {
+		concurrency = maxBulkPushConcurrency
+	}
+
+	now := time.Now()
+	tasks := make(chan *models.FrpcConfig)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for client := range tasks {
+				onResult(bulkPushClient(client, now, author))
+			}
+		}()
+	}
+	for i := range clients {
+		tasks <- &clients[i]
+	}
+	close(tasks)
+	wg.Wait()
+}
+
+// bulkPushJob 后台批量推送任务，进度通过任务接口轮询
+type bulkPushJob struct {
+	mu         sync.Mutex
+	id         string
+	author     string
+	total      int
+	results    []bulkPushResult
+	startedAt  time.Time
+	finishedAt *time.Time
+}
+
+func (j *bulkPushJob) add(r bulkPushResult) {
+	j.mu.Lock()
+	j.results = append(j.results, r)
+	j.mu.Unlock()
+}
+
+func (j *bulkPushJob) finish() {
+	j.mu.Lock()
+	now := time.Now()
+	j.finishedAt = &now
+	j.mu.Unlock()
+}
+
+// snapshot 返回任务当前的进度，用于 JSON 输出
+func (j *bulkPushJob) snapshot() gin.H {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	results := make([]bulkPushResult, len(j.results))
+	copy(results, j.results)
+	status := "running"
+	if j.finishedAt != nil {
+		status = "done"
+	}
+	return gin.H{
+		"id":          j.id,
+		"status":      status,
+		"author":      j.author,
+		"total":       j.total,
+		"done":        len(results),
+		"summary":     summarizeBulkPush(results),
+		"results":     results,
+		"started_at":  j.startedAt,
+		"finished_at": j.finishedAt,
+	}
+}
+
+var bulkJobs = struct {
+	sync.Mutex
+	jobs map[string]*bulkPushJob
+}{jobs: make(map[string]*bulkPushJob)}
+
+// newBulkPushJob 登记新的后台任务，并清理过期的已结束任务
+func newBulkPushJob(author string, total int) *bulkPushJob {
+	job := &bulkPushJob{
+		id:        config.GenerateRandomToken()[:16],
+		author:    author,
+		total:     total,
+		startedAt: time.Now(),
+	}
+
+	bulkJobs.Lock()
+	defer bulkJobs.Unlock()
+	for id, j := range bulkJobs.jobs {
+		j.mu.Lock()
+		expired := j.finishedAt != nil && time.Since(*j.finishedAt) > bulkJobRetention
+		j.mu.Unlock()
+		if expired {
+			delete(bulkJobs.jobs, id)
+		}
+	}
+	bulkJobs.jobs[job.id] = job
+	return job
+}
+
+func summarizeBulkPush(results []bulkPushResult) gin.H {
+	summary := gin.H{"success": 0, "failed": 0, "skipped": 0}
+	for _, r := range results {
+		summary[r.Status] = summary[r.Status].(int) + 1
+	}
+	return summary
+}
+
+// 批量推送配置并重载 frpc，client_ids 为空时推送全部启用管理接口的客户端。
+// background 为 true 时立即返回任务 ID，进度通过 GET /jobs/:id 查询
+func bulkReloadHandler(c *gin.Context) {
+	var req struct {
+		ClientIDs   []uint `json:"client_ids"`
+		Concurrency int    `json:"concurrency"`
+		Background  bool   `json:"background"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	query := db.Preload("Proxies").Preload("Visitors")
+	if len(req.ClientIDs) > 0 {
+		query = query.Where("id IN ?", req.ClientIDs)
+	} else {
+		query = query.Where("admin_enabled = ?", true)
+	}
+	var clients []models.FrpcConfig
+	query.Find(&clients)
+	if len(clients) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "没有可推送的客户端"})
+		return
+	}
+
+	author := c.GetString("username")
+	if req.Background {
+		job := newBulkPushJob(author, len(clients))
+		go func() {
+			runBulkPush(clients, req.Concurrency, author, job.add)
+			job.finish()
+		}()
+		c.JSON(http.StatusAccepted, gin.H{"job_id": job.id, "total": len(clients)})
+		return
+	}
+
+	var mu sync.Mutex
+	results := make([]bulkPushResult, 0, len(clients))
+	runBulkPush(clients, req.Concurrency, author, func(r bulkPushResult) {
+		mu.Lock()
+		results = append(results, r)
+		mu.Unlock()
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"total":   len(clients),
+		"summary": summarizeBulkPush(results),
+		"results": results,
+	})
+}
+
+// 查询后台批量推送任务的进度
+func getBulkJobHandler(c *gin.Context) {
+	bulkJobs.Lock()
+	job, ok := bulkJobs.jobs[c.Param("id")]
+	bulkJobs.Unlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job.snapshot())
+}
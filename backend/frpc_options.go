@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"frp-admin/config"
+	"frp-admin/models"
+	"frp-admin/utils"
+)
+
+// ============= frpc 客户端选项 =============
+
+// 系统设置中 frpc 默认选项的键，客户端未设置的项使用这里的值
+var frpcOptionSettingKeys = []string{
+	"frpc_transport_protocol",
+	"frpc_dial_server_timeout",
+	"frpc_proxy_url",
+	"frpc_pool_count",
+	"frpc_tcp_mux",
+	"frpc_heartbeat_interval",
+	"frpc_heartbeat_timeout",
+	"frpc_tls_enable",
+	"frpc_tls_cert_file",
+	"frpc_tls_key_file",
+	"frpc_tls_trusted_ca_file",
+	"frpc_tls_server_name",
+	"frpc_tls_disable_custom_tls_first_byte",
+	"frpc_login_fail_exit",
+	"frpc_log_to",
+	"frpc_log_level",
+	"frpc_log_max_days",
+}
+
+// frpcOptionsFromSettings 将设置中的字符串值转换为 frpc 选项，空值表示未设置
+func frpcOptionsFromSettings(values map[string]string) (utils.FrpcClientOptions, error) {
+	var o utils.FrpcClientOptions
+	var errs []string
+
+	intValue := func(key string) int {
+		v := strings.TrimSpace(values[key])
+		if v == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s 应为整数", key))
+		}
+		return n
+	}
+	boolValue := func(key string) *bool {
+		v := strings.TrimSpace(values[key])
+		if v == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s 应为 true 或 false", key))
+			return nil
+		}
+		return &b
+	}
+
+	o.TransportProtocol = strings.TrimSpace(values["frpc_transport_protocol"])
+	o.DialServerTimeout = intValue("frpc_dial_server_timeout")
+	o.ProxyURL = strings.TrimSpace(values["frpc_proxy_url"])
+	o.PoolCount = intValue("frpc_pool_count")
+	o.TCPMux = boolValue("frpc_tcp_mux")
+	o.HeartbeatInterval = intValue("frpc_heartbeat_interval")
+	o.HeartbeatTimeout = intValue("frpc_heartbeat_timeout")
+	o.TLSEnable = boolValue("frpc_tls_enable")
+	o.TLSCertFile = strings.TrimSpace(values["frpc_tls_cert_file"])
+	o.TLSKeyFile = strings.TrimSpace(values["frpc_tls_key_file"])
+	o.TLSTrustedCaFile = strings.TrimSpace(values["frpc_tls_trusted_ca_file"])
+	o.TLSServerName = strings.TrimSpace(values["frpc_tls_server_name"])
+	o.TLSDisableCustomTLSFirstByte = boolValue("frpc_tls_disable_custom_tls_first_byte")
+	o.LoginFailExit = boolValue("frpc_login_fail_exit")
+	o.LogTo = strings.TrimSpace(values["frpc_log_to"])
+	o.LogLevel = strings.TrimSpace(values["frpc_log_level"])
+	o.LogMaxDays = intValue("frpc_log_max_days")
+
+	if len(errs) > 0 {
+		return o, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	if err := utils.ValidateFrpcClientOptions(o); err != nil {
+		return o, err
+	}
+	return o, nil
+}
+
+// loadFrpcDefaultOptions 读取系统设置中的 frpc 默认选项
+func loadFrpcDefaultOptions() utils.FrpcClientOptions {
+	var settings []models.Setting
+	db.Where("key IN ?", frpcOptionSettingKeys).Find(&settings)
+
+	values := make(map[string]string)
+	for _, s := range settings {
+		values[s.Key] = s.Value
+	}
+	// 保存设置时已校验，这里忽略错误，无法解析的项视为未设置
+	o, _ := frpcOptionsFromSettings(values)
+	return o
+}
+
+// clientFrpcOptions 取出客户端自身设置的选项
+func clientFrpcOptions(client *models.FrpcConfig) utils.FrpcClientOptions {
+	return utils.FrpcClientOptions{
+		TransportProtocol:            client.TransportProtocol,
+		DialServerTimeout:            client.DialServerTimeout,
+		ProxyURL:                     client.ProxyURL,
+		PoolCount:                    client.PoolCount,
+		TCPMux:                       client.TCPMux,
+		HeartbeatInterval:            client.HeartbeatInterval,
+		HeartbeatTimeout:             client.HeartbeatTimeout,
+		TLSEnable:                    client.TLSEnable,
+		TLSCertFile:                  client.TLSCertFile,
+		TLSKeyFile:                   client.TLSKeyFile,
+		TLSTrustedCaFile:             client.TLSTrustedCaFile,
+		TLSServerName:                client.TLSServerName,
+		TLSDisableCustomTLSFirstByte: client.TLSDisableCustomTLSFirstByte,
+		LoginFailExit:                client.LoginFailExit,
+		LogTo:                        client.LogTo,
+		LogLevel:                     client.LogLevel,
+		LogMaxDays:                   client.LogMaxDays,
+	}
+}
+
+// resolveFrpcOptions 合并客户端选项和系统默认选项
+func resolveFrpcOptions(client *models.FrpcConfig) utils.FrpcClientOptions {
+	return utils.MergeFrpcClientOptions(clientFrpcOptions(client), loadFrpcDefaultOptions())
+}
+
+// validateClientFrpcOptions 校验客户端选项，并检查 frps 是否支持最终使用的传输协议
+func validateClientFrpcOptions(client *models.FrpcConfig) error {
+	if err := utils.ValidateFrpcClientOptions(clientFrpcOptions(client)); err != nil {
+		return err
+	}
+
+	frpsConfig, err := utils.ParseFrpsToml(config.AppConfig.FrpsConfig)
+	if err != nil {
+		return nil
+	}
+	switch resolveFrpcOptions(client).TransportProtocol {
+	case "kcp":
+		if frpsConfig.KCPBindPort <= 0 {
+			return fmt.Errorf("frps 未配置 kcpBindPort，无法使用 KCP 协议")
+		}
+	case "quic":
+		if frpsConfig.QUICBindPort <= 0 {
+			return fmt.Errorf("frps 未配置 quicBindPort，无法使用 QUIC 协议")
+		}
+	}
+	return nil
+}
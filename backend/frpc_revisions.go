@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"frp-admin/models"
+	"frp-admin/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============= frpc 配置下发记录 Handler =============
+
+// maxFrpcRevisionsPerClient 每个客户端保留的下发记录数
+const maxFrpcRevisionsPerClient = 50
+
+// recordFrpcRevision 记录一次下载或推送的 frpc 配置
+// 内容与最近一条记录相同时不重复插入，直接返回最近的记录
+func recordFrpcRevision(client *models.FrpcConfig, content, action, author string) *models.FrpcConfigRevision {
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+
+	var latest models.FrpcConfigRevision
+	if err := db.Where("frpc_config_id = ?", client.ID).Order("id DESC").First(&latest).Error; err == nil && latest.Hash == hash {
+		return &latest
+	}
+
+	revision := &models.FrpcConfigRevision{
+		FrpcConfigID: client.ID,
+		Content:      content,
+		Hash:         hash,
+		Action:       action,
+		Author:       author,
+	}
+	db.Create(revision)
+	pruneFrpcRevisions(client.ID)
+	return revision
+}
+
+// pruneFrpcRevisions 删除超出保留数量的旧记录
+func pruneFrpcRevisions(clientID uint) {
+	var cutoff models.FrpcConfigRevision
+	err := db.Select("id").Where("frpc_config_id = ?", clientID).
+		Order("id DESC").Offset(maxFrpcRevisionsPerClient - 1).First(&cutoff).Error
+	if err != nil {
+		return
+	}
+	db.Where("frpc_config_id = ? AND id < ?", clientID, cutoff.ID).Delete(&models.FrpcConfigRevision{})
+}
+
+func getFrpcRevisionsHandler(c *gin.Context) {
+	clientID := c.Param("id")
+	var revisions []models.FrpcConfigRevision
+	db.Omit("content").Where("frpc_config_id = ?", clientID).Order("id DESC").Limit(maxFrpcRevisionsPerClient).Find(&revisions)
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+func getFrpcRevisionHandler(c *gin.Context) {
+	id := c.Param("id")
+	var revision models.FrpcConfigRevision
+	if err := db.First(&revision, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	c.JSON(http.StatusOK, revision)
+}
+
+// 漂移检测：比较 frpc 当前运行的配置与最近一次下发的配置
+// 没有下发记录时与当前生成的配置比较
+func frpcDriftHandler(c *gin.Context) {
+	id := c.Param("id")
+	var client models.FrpcConfig
+	if err := db.Preload("Proxies").Preload("Visitors").First(&client, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	frpcClient, err := getFrpcClient(&client)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var baseline models.FrpcConfigRevision
+	expected := ""
+	var revisionID uint
+	if err := db.Where("frpc_config_id = ?", client.ID).Order("id DESC").First(&baseline).Error; err == nil {
+		expected = baseline.Content
+		revisionID = baseline.ID
+	} else {
+		expected, err = generateClientToml(&client)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成配置失败: " + err.Error()})
+			return
+		}
+	}
+
+	live, err := frpcClient.GetConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法连接到 frpc: " + err.Error()})
+		return
+	}
+
+	diff, err := utils.CompareFrpcConfigs(expected, live)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "配置解析失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"revision_id": revisionID, // 0 表示与当前生成的配置比较
+		"drift":       diff,
+	})
+}
+
+// lastPushedRevision 返回最近一次推送到 frpc（包括回滚）的配置记录
+func lastPushedRevision(clientID uint) (*models.FrpcConfigRevision, bool) {
+	var revision models.FrpcConfigRevision
+	if err := db.Where("frpc_config_id = ? AND action IN ?", clientID, []string{"push", "rollback"}).Order("id DESC").First(&revision).Error; err != nil {
+		return nil, false
+	}
+	return &revision, true
+}
+
+// previewRevision 标识一次预览：最近一次推送的记录和将要生成的配置，任一变化都会改变该值
+func previewRevision(clientID uint, generated string) string {
+	var revisionID uint
+	if revision, ok := lastPushedRevision(clientID); ok {
+		revisionID = revision.ID
+	}
+	sum := sha256.Sum256([]byte(generated))
+	return fmt.Sprintf("%d:%s", revisionID, hex.EncodeToString(sum[:8]))
+}
+
+// 预览重载将产生的变化：对比 frpc 当前运行的配置（base=live）或最近一次推送的配置（base=pushed）
+// 与当前生成的配置。返回的 expected_revision 可传给重载接口，防止按过期的预览推送
+func frpcPreviewHandler(c *gin.Context) {
+	id := c.Param("id")
+	var client models.FrpcConfig
+	if err := db.Preload("Proxies").Preload("Visitors").First(&client, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	// 预览的是重载时实际推送的配置，不包含当前不允许访问的代理
+	generated, err := generateClientToml(allowedProxiesClient(&client, time.Now()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成配置失败: " + err.Error()})
+		return
+	}
+
+	base := c.DefaultQuery("base", "live")
+	var current string
+	var revisionID uint
+	switch base {
+	case "live":
+		frpcClient, err := getFrpcClient(&client)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		current, err = frpcClient.GetConfig()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "无法连接到 frpc: " + err.Error()})
+			return
+		}
+	case "pushed":
+		if revision, ok := lastPushedRevision(client.ID); ok {
+			current = revision.Content
+			revisionID = revision.ID
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "base 应为 live 或 pushed"})
+		return
+	}
+
+	diff, err := utils.CompareFrpcConfigs(current, generated)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "配置解析失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"base":              base,
+		"revision_id":       revisionID, // base=pushed 时对比的记录，0 表示尚未推送过
+		"diff":              diff,
+		"unified":           utils.UnifiedDiff(base, "generated", current, generated, 3),
+		"expected_revision": previewRevision(client.ID, generated),
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"frp-admin/config"
+	"frp-admin/models"
+	"frp-admin/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============= frps 配置历史 Handler =============
+
+// recordInitialFrpsConfigVersion 首次保存前把磁盘上的原始配置记录为初始版本，保证可以回滚
+func recordInitialFrpsConfigVersion() {
+	var count int64
+	db.Model(&models.FrpsConfigVersion{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	data, err := os.ReadFile(config.AppConfig.FrpsConfig)
+	if err != nil {
+		return
+	}
+
+	version := &models.FrpsConfigVersion{
+		Content: string(data),
+		Author:  "system",
+		Source:  "initial",
+	}
+	if err := utils.GetFrpsManager().Verify(config.AppConfig.FrpsConfig); err != nil {
+		version.VerifyError = err.Error()
+	} else {
+		version.Verified = true
+	}
+	db.Create(version)
+}
+
+func getFrpsConfigVersionsHandler(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	var versions []models.FrpsConfigVersion
+	db.Omit("content").Order("id DESC").Limit(limit).Find(&versions)
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+func getFrpsConfigVersionHandler(c *gin.Context) {
+	id := c.Param("id")
+	var version models.FrpsConfigVersion
+	if err := db.First(&version, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
+
+// loadFrpsConfigContent 读取指定版本的配置内容，id 为空或 current 时读取当前配置文件
+func loadFrpsConfigContent(id string) (string, string, error) {
+	if id == "" || id == "current" {
+		data, err := os.ReadFile(config.AppConfig.FrpsConfig)
+		if err != nil {
+			return "", "", fmt.Errorf("Failed to read config file")
+		}
+		return string(data), "current", nil
+	}
+
+	var version models.FrpsConfigVersion
+	if err := db.First(&version, id).Error; err != nil {
+		return "", "", fmt.Errorf("Version %s not found", id)
+	}
+	return version.Content, fmt.Sprintf("version-%d", version.ID), nil
+}
+
+// 比较两个版本，from/to 为版本 ID，省略或为 current 时表示当前配置文件
+func diffFrpsConfigVersionsHandler(c *gin.Context) {
+	fromContent, fromName, err := loadFrpsConfigContent(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	toContent, toName, err := loadFrpsConfigContent(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from": fromName,
+		"to":   toName,
+		"diff": utils.UnifiedDiff(fromName, toName, fromContent, toContent, 3),
+	})
+}
+
+// 回滚到指定版本，重新验证后保存，可选重启 frps
+func rollbackFrpsConfigHandler(c *gin.Context) {
+	id := c.Param("id")
+	var target models.FrpsConfigVersion
+	if err := db.First(&target, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+
+	var req struct {
+		Restart bool `json:"restart"`
+	}
+	c.ShouldBindJSON(&req)
+
+	// 回滚来源与新版本在同一次加锁中记录
+	frpsConfigMu.Lock()
+	version, status, err := writeFrpsConfig(target.Content, c.GetString("username"), "rollback")
+	if version != nil {
+		db.Model(version).Update("rollback_of", target.ID)
+	}
+	frpsConfigMu.Unlock()
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := gin.H{
+		"message":    fmt.Sprintf("已回滚到版本 %d", target.ID),
+		"version_id": version.ID,
+	}
+	if req.Restart {
+		restart, err := utils.GetFrpsManager().Restart()
+		if err != nil {
+			result["restart_error"] = err.Error()
+			c.JSON(http.StatusInternalServerError, result)
+			return
+		}
+		result["restarted"] = true
+		result["restart"] = restart
+	}
+
+	c.JSON(http.StatusOK, result)
+}
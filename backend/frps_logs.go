@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"frp-admin/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============= frps 日志查询 =============
+
+const (
+	defaultLogQueryLimit = 100
+	maxLogQueryLimit     = 1000
+)
+
+// parseLogTime 解析查询参数中的时间，支持 RFC3339 和本地时间 2006-01-02 15:04:05
+func parseLogTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+}
+
+// logFilterFromQuery 读取查询和实时日志共用的过滤参数：level、q、proxy、user
+func logFilterFromQuery(c *gin.Context) (utils.FrpsLogQuery, error) {
+	q := utils.FrpsLogQuery{
+		Level:  c.Query("level"),
+		Search: c.Query("q"),
+		Proxy:  c.Query("proxy"),
+		User:   c.Query("user"),
+	}
+	if q.Level != "" && !utils.ValidFrpsLogLevel(q.Level) {
+		return q, fmt.Errorf("level 应为 trace、debug、info、warn 或 error")
+	}
+	return q, nil
+}
+
+// 查询 frps 日志：level 最低级别，since/until 时间范围，q 文本搜索，proxy/user 按代理或客户端过滤，
+// offset/limit 分页（按时间倒序）
+func queryFrpsLogsHandler(c *gin.Context) {
+	q, err := logFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.Limit = defaultLogQueryLimit
+	for name, target := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := c.Query(name); v != "" {
+			t, err := parseLogTime(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + " 时间格式错误"})
+				return
+			}
+			*target = t
+		}
+	}
+	if v, err := strconv.Atoi(c.Query("offset")); err == nil && v > 0 {
+		q.Offset = v
+	}
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		q.Limit = v
+	}
+	if q.Limit > maxLogQueryLimit {
+		q.Limit = maxLogQueryLimit
+	}
+
+	page, err := utils.GetFrpsManager().QueryLogs(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// 实时日志的心跳间隔，防止代理服务器断开空闲连接
+const logStreamHeartbeat = 15 * time.Second
+
+// 以 Server-Sent Events 推送新的 frps 日志，过滤参数与查询接口相同。
+// 需要 Authorization 头，JWT 过期时服务端结束推送
+func streamFrpsLogsHandler(c *gin.Context) {
+	q, err := logFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	lines, err := utils.GetFrpsManager().FollowLogs(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var expired <-chan time.Time
+	if exp, ok := c.Get("token_exp"); ok {
+		if v, ok := exp.(float64); ok {
+			timer := time.NewTimer(time.Until(time.Unix(int64(v), 0)))
+			defer timer.Stop()
+			expired = timer.C
+		}
+	}
+	heartbeat := time.NewTicker(logStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return false
+			}
+			entry := utils.ParseFrpsLogLine(line)
+			if q.Match(&entry) {
+				c.SSEvent("log", entry)
+			}
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-expired:
+			c.SSEvent("end", "token expired")
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
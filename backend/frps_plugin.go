@@ -0,0 +1,401 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"frp-admin/config"
+	"frp-admin/models"
+	"frp-admin/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============= frps 服务端插件 Handler =============
+
+const frpsPluginName = "frp-admin"
+
+// frp-admin 插件需要处理的操作
+var frpsPluginOps = []string{"Login", "NewProxy"}
+
+// frpsPluginEnforcing 插件校验模式，enforce（默认）拒绝未通过的请求，observe 只记录不拒绝
+func frpsPluginEnforcing() bool {
+	var setting models.Setting
+	db.Where("key = ?", "frps_plugin_mode").First(&setting)
+	return setting.Value != "observe"
+}
+
+// missingFrpsPluginOps 返回已安装插件缺少的操作
+func missingFrpsPluginOps(plugin *utils.FrpsHTTPPlugin) []string {
+	have := make(map[string]bool)
+	for _, op := range plugin.Ops {
+		have[op] = true
+	}
+	missing := []string{}
+	for _, op := range frpsPluginOps {
+		if !have[op] {
+			missing = append(missing, op)
+		}
+	}
+	return missing
+}
+
+// frpsPluginKey 插件地址中的随机密钥，防止插件接口被直接调用，首次使用时生成
+func frpsPluginKey() string {
+	var setting models.Setting
+	if err := db.Where("key = ?", "frps_plugin_key").First(&setting).Error; err == nil && setting.Value != "" {
+		return setting.Value
+	}
+	key := config.GenerateRandomToken()
+	db.Where("key = ?", "frps_plugin_key").Assign(models.Setting{Value: key}).FirstOrCreate(&models.Setting{Key: "frps_plugin_key"})
+	return key
+}
+
+// frpsPluginAddr frps 访问 frp-admin 的地址，默认本机，可通过 frps_plugin_addr 设置覆盖
+func frpsPluginAddr() string {
+	var setting models.Setting
+	if err := db.Where("key = ?", "frps_plugin_addr").First(&setting).Error; err == nil && setting.Value != "" {
+		return setting.Value
+	}
+	return "127.0.0.1:" + config.AppConfig.Port
+}
+
+func frpsPluginPath() string {
+	return "/api/frps/plugin/" + frpsPluginKey()
+}
+
+// findInstalledFrpsPlugin 查找 frps 配置中指向 frp-admin 的插件
+func findInstalledFrpsPlugin(frpsConfig *utils.FrpsConfig) *utils.FrpsHTTPPlugin {
+	if frpsConfig == nil {
+		return nil
+	}
+	path := frpsPluginPath()
+	for i := range frpsConfig.HTTPPlugins {
+		if frpsConfig.HTTPPlugins[i].Path == path {
+			return &frpsConfig.HTTPPlugins[i]
+		}
+	}
+	return nil
+}
+
+// ensureClientAuthSecrets 为还没有密钥的客户端生成密钥
+func ensureClientAuthSecrets() {
+	var clients []models.FrpcConfig
+	db.Where("auth_secret = ? OR auth_secret IS NULL", "").Find(&clients)
+	for _, client := range clients {
+		db.Model(&client).Update("auth_secret", config.GenerateRandomToken())
+	}
+}
+
+// 重新生成客户端密钥，旧密钥立即失效（frpc 下次登录时被插件拒绝），并尝试推送包含新密钥的配置。
+// 密钥属于 frpc 通用配置，reload 不会重新读取，推送成功后仍需重启 frpc 进程
+func rotateClientSecretHandler(c *gin.Context) {
+	id := c.Param("id")
+	var client models.FrpcConfig
+	if err := db.Preload("Proxies").Preload("Visitors").First(&client, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	client.AuthSecret = config.GenerateRandomToken()
+	if err := db.Model(&client).Update("auth_secret", client.AuthSecret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate client secret"})
+		return
+	}
+
+	if err := pushAllowedProxies(&client, time.Now(), c.GetString("username")); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "密钥已重新生成，旧密钥已失效，请重新下载配置并重启 frpc",
+			"pushed":     false,
+			"push_error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "密钥已重新生成并推送，需重启 frpc 进程后才会使用新密钥",
+		"pushed":  true,
+	})
+}
+
+// 查看插件安装状态和需要添加到 frps.toml 的配置片段
+func getFrpsPluginHandler(c *gin.Context) {
+	snippet, err := utils.BuildFrpsPluginSnippet(frpsPluginName, frpsPluginAddr(), frpsPluginPath(), frpsPluginOps)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	frpsConfig, _ := utils.ParseFrpsToml(config.AppConfig.FrpsConfig)
+	installed := findInstalledFrpsPlugin(frpsConfig)
+	missingOps := []string{}
+	if installed != nil {
+		missingOps = missingFrpsPluginOps(installed)
+	}
+	mode := "enforce"
+	if !frpsPluginEnforcing() {
+		mode = "observe"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"installed":   installed != nil,
+		"plugin":      installed,
+		"ops":         frpsPluginOps,
+		"missing_ops": missingOps,
+		"mode":        mode,
+		"snippet":     snippet,
+	})
+}
+
+// 将插件配置写入 frps.toml，已安装但缺少操作时更新 ops，重启 frps 后生效
+func installFrpsPluginHandler(c *gin.Context) {
+	var req struct {
+		Restart bool `json:"restart"`
+	}
+	c.ShouldBindJSON(&req)
+
+	version, status, err := updateFrpsConfig(c.GetString("username"), "plugin", func(current string) (string, int, error) {
+		frpsConfig, err := utils.ParseFrpsTomlContent([]byte(current))
+		if err != nil {
+			return "", http.StatusBadRequest, err
+		}
+		if installed := findInstalledFrpsPlugin(frpsConfig); installed != nil {
+			if len(missingFrpsPluginOps(installed)) == 0 {
+				return "", http.StatusBadRequest, fmt.Errorf("插件已安装")
+			}
+			content, err := utils.SetFrpsPluginOps(current, installed.Path, frpsPluginOps)
+			return content, http.StatusInternalServerError, err
+		}
+		snippet, err := utils.BuildFrpsPluginSnippet(frpsPluginName, frpsPluginAddr(), frpsPluginPath(), frpsPluginOps)
+		if err != nil {
+			return "", http.StatusInternalServerError, err
+		}
+		content, err := utils.AppendFrpsPlugin(current, snippet)
+		return content, http.StatusInternalServerError, err
+	})
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := gin.H{"message": "插件已写入 frps 配置", "version_id": version.ID}
+	if req.Restart {
+		restart, err := utils.GetFrpsManager().Restart()
+		if err != nil {
+			result["restart_error"] = err.Error()
+			c.JSON(http.StatusInternalServerError, result)
+			return
+		}
+		result["restarted"] = true
+		result["restart"] = restart
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// frpsPluginHandler 处理 frps 发来的插件请求，不使用 JWT，通过地址中的密钥校验
+func frpsPluginHandler(c *gin.Context) {
+	if subtle.ConstantTimeCompare([]byte(c.Param("key")), []byte(frpsPluginKey())) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	var req utils.FrpsPluginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var resp utils.FrpsPluginResponse
+	switch req.Op {
+	case "Login":
+		resp = handlePluginLogin(req.Content)
+	case "NewProxy":
+		resp = handlePluginNewProxy(req.Content)
+	default:
+		// 未处理的操作直接放行
+		resp = utils.FrpsPluginResponse{Unchange: true}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handlePluginLogin 按 user 查找客户端并校验 metadatas 中的密钥
+func handlePluginLogin(raw json.RawMessage) utils.FrpsPluginResponse {
+	var content utils.FrpsPluginLoginContent
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return rejectPluginRequest("invalid login content")
+	}
+
+	reason := checkClientLogin(&content)
+	if reason == "" {
+		return utils.FrpsPluginResponse{Unchange: true}
+	}
+	return auditPluginRejection(models.FrpsPluginAudit{
+		Op:            "Login",
+		User:          content.User,
+		ClientAddress: content.ClientAddress,
+		Reason:        reason,
+	})
+}
+
+func checkClientLogin(content *utils.FrpsPluginLoginContent) string {
+	if strings.TrimSpace(content.User) == "" {
+		return "user is required"
+	}
+
+	var client models.FrpcConfig
+	if err := db.Where("user = ?", content.User).First(&client).Error; err != nil {
+		return fmt.Sprintf("unknown user %s", content.User)
+	}
+
+	secret := content.Metas[utils.FrpcAuthSecretMetaKey]
+	if client.AuthSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(client.AuthSecret)) != 1 {
+		return "invalid client secret"
+	}
+	if reason := clientAccessDenied(&client, time.Now()); reason != "" {
+		return "client " + reason
+	}
+	return ""
+}
+
+// handlePluginNewProxy 校验客户端注册的代理是否与数据库中的配置一致
+func handlePluginNewProxy(raw json.RawMessage) utils.FrpsPluginResponse {
+	var content utils.FrpsPluginNewProxyContent
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return rejectPluginRequest("invalid new proxy content")
+	}
+
+	reason := checkClientNewProxy(&content)
+	if reason == "" {
+		return utils.FrpsPluginResponse{Unchange: true}
+	}
+	return auditPluginRejection(models.FrpsPluginAudit{
+		Op:         "NewProxy",
+		User:       content.User.User,
+		ProxyName:  content.ProxyName,
+		ProxyType:  content.ProxyType,
+		RemotePort: content.RemotePort,
+		Reason:     reason,
+	})
+}
+
+func checkClientNewProxy(content *utils.FrpsPluginNewProxyContent) string {
+	var client models.FrpcConfig
+	if err := db.Where("user = ?", content.User.User).First(&client).Error; err != nil {
+		return fmt.Sprintf("unknown user %s", content.User.User)
+	}
+	now := time.Now()
+	if reason := clientAccessDenied(&client, now); reason != "" {
+		return "client " + reason
+	}
+
+	// frpc 设置了 user 时，代理名称带有 "user." 前缀，去掉后得到配置中的名称
+	name := strings.TrimPrefix(content.ProxyName, frpProxyName(&client, ""))
+
+	// 系统自动生成的在线管理代理
+	if name == frpcAdminProxyName && client.AdminEnabled {
+		if content.ProxyType != "tcp" || content.RemotePort != client.AdminRemotePort {
+			return fmt.Sprintf("frpc-admin must use tcp remote port %d", client.AdminRemotePort)
+		}
+		return ""
+	}
+
+	var proxy models.Proxy
+	if err := db.Where("frpc_config_id = ? AND name = ?", client.ID, name).First(&proxy).Error; err != nil {
+		return fmt.Sprintf("proxy %s is not registered in frp-admin", name)
+	}
+	if reason := proxyAccessDenied(&proxy, now); reason != "" {
+		return fmt.Sprintf("proxy %s %s", name, reason)
+	}
+	if proxy.Type != content.ProxyType {
+		return fmt.Sprintf("proxy %s type mismatch: expected %s, got %s", name, proxy.Type, content.ProxyType)
+	}
+
+	expectedPort := proxy.RemotePort
+	groupName := ""
+	if proxy.ProxyGroupID != nil {
+		var group models.ProxyGroup
+		if err := db.First(&group, *proxy.ProxyGroupID).Error; err == nil {
+			groupName = group.Name
+			if group.Type == "tcp" {
+				expectedPort = group.RemotePort
+			}
+		}
+	}
+	if content.Group != groupName {
+		return fmt.Sprintf("proxy %s load balancer group mismatch: expected %q, got %q", name, groupName, content.Group)
+	}
+
+	if proxy.Type != "tcp" && proxy.Type != "udp" {
+		return ""
+	}
+	if expectedPort > 0 && content.RemotePort != expectedPort {
+		return fmt.Sprintf("proxy %s remote port mismatch: expected %d, got %d", name, expectedPort, content.RemotePort)
+	}
+	if expectedPort == 0 && content.RemotePort > 0 {
+		// 配置为随机端口时，不能占用其他代理登记的端口
+		var other models.Proxy
+		if err := db.Where("type = ? AND remote_port = ? AND id != ?", proxy.Type, content.RemotePort, proxy.ID).First(&other).Error; err == nil {
+			return fmt.Sprintf("remote port %d is reserved by another proxy", content.RemotePort)
+		}
+		if proxy.Type == "tcp" {
+			if err := checkTCPPortReserved(content.RemotePort, nil); err != nil {
+				return fmt.Sprintf("remote port %d is reserved", content.RemotePort)
+			}
+		}
+	}
+	return ""
+}
+
+// auditPluginRejection 记录校验未通过的请求，观察模式下只记录不拒绝
+func auditPluginRejection(audit models.FrpsPluginAudit) utils.FrpsPluginResponse {
+	audit.Enforced = frpsPluginEnforcing()
+	db.Create(&audit)
+
+	if !audit.Enforced {
+		log.Printf("frps plugin: [observe] %s user=%q proxy=%q: %s", audit.Op, audit.User, audit.ProxyName, audit.Reason)
+		return utils.FrpsPluginResponse{Unchange: true}
+	}
+	log.Printf("frps plugin: reject %s user=%q proxy=%q: %s", audit.Op, audit.User, audit.ProxyName, audit.Reason)
+	return rejectPluginRequest(audit.Reason)
+}
+
+func rejectPluginRequest(reason string) utils.FrpsPluginResponse {
+	return utils.FrpsPluginResponse{Reject: true, RejectReason: reason}
+}
+
+// 查询插件拒绝记录，可按 op、user 过滤
+func getFrpsPluginAuditsHandler(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	query := db.Model(&models.FrpsPluginAudit{})
+	if op := c.Query("op"); op != "" {
+		query = query.Where("op = ?", op)
+	}
+	if user := c.Query("user"); user != "" {
+		query = query.Where("user = ?", user)
+	}
+
+	var audits []models.FrpsPluginAudit
+	query.Order("id DESC").Limit(limit).Find(&audits)
+	c.JSON(http.StatusOK, gin.H{"audits": audits})
+}
+
+// 切换插件校验模式：enforce 或 observe
+func setFrpsPluginModeHandler(c *gin.Context) {
+	var req struct {
+		Mode string `json:"mode" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Mode != "enforce" && req.Mode != "observe") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode 应为 enforce 或 observe"})
+		return
+	}
+	db.Where("key = ?", "frps_plugin_mode").Assign(models.Setting{Value: req.Mode}).FirstOrCreate(&models.Setting{Key: "frps_plugin_mode"})
+	c.JSON(http.StatusOK, gin.H{"message": "Plugin mode updated", "mode": req.Mode})
+}
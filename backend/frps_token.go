@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"frp-admin/config"
+	"frp-admin/models"
+	"frp-admin/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============= frps auth.token 轮换 =============
+
+// tokenRotationClient 轮换结果中的单个客户端
+type tokenRotationClient struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// pendingTokenRotation 已写入新 token、等待确认重启 frps 的轮换
+var pendingTokenRotation struct {
+	sync.Mutex
+	versionID uint
+}
+
+// 生成新的 auth.token 写入 frps.toml，并向所有启用管理接口的客户端推送新配置。
+// frpc 的 reload 只重新加载代理和访问者，auth.token 属于通用配置，只在 frpc 启动时读取，
+// 所以推送成功的客户端仍需重启 frpc 进程。frps 不会自动重启：确认客户端都已处理后再调用确认接口重启
+func rotateFrpsTokenHandler(c *gin.Context) {
+	author := c.GetString("username")
+	version, status, err := updateFrpsConfig(author, "rotate", func(current string) (string, int, error) {
+		frpsConfig, err := utils.ParseFrpsTomlContent([]byte(current))
+		if err != nil {
+			return "", http.StatusBadRequest, err
+		}
+		if frpsConfig.Auth.Method != "" && frpsConfig.Auth.Method != "token" {
+			return "", http.StatusBadRequest, fmt.Errorf("frps 认证方式为 %s，无法轮换 token", frpsConfig.Auth.Method)
+		}
+		content, err := utils.ApplyFrpsSettings(current, map[string]interface{}{
+			"auth.token": config.GenerateRandomToken(),
+		})
+		return content, http.StatusInternalServerError, err
+	})
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// frps.toml 已保存，生成的客户端配置会使用新 token
+	pushed, manual := pushRotatedToken(author)
+
+	restartRequired := utils.GetFrpsManager().Status().Running
+	pendingTokenRotation.Lock()
+	if restartRequired {
+		pendingTokenRotation.versionID = version.ID
+	} else {
+		pendingTokenRotation.versionID = 0
+	}
+	pendingTokenRotation.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "新 token 已写入 frps 配置，已推送的客户端需要重启 frpc 进程后才会使用新 token",
+		"version_id":       version.ID,
+		"pushed":           pushed,
+		"manual":           manual,
+		"restart_required": restartRequired,
+	})
+}
+
+// pushRotatedToken 向客户端推送包含新 token 的配置，返回已写入配置（仍需重启 frpc）和需要手动更新配置的客户端
+func pushRotatedToken(author string) ([]tokenRotationClient, []tokenRotationClient) {
+	var clients []models.FrpcConfig
+	db.Preload("Proxies").Preload("Visitors").Find(&clients)
+
+	var mu sync.Mutex
+	pushed := []tokenRotationClient{}
+	manual := []tokenRotationClient{}
+	runBulkPush(clients, defaultBulkPushConcurrency, author, func(r bulkPushResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Status == "success" {
+			pushed = append(pushed, tokenRotationClient{ID: r.ID, Name: r.Name, Reason: "配置已推送，需重启 frpc 进程"})
+		} else {
+			manual = append(manual, tokenRotationClient{ID: r.ID, Name: r.Name, Reason: r.Error})
+		}
+	})
+	return pushed, manual
+}
+
+// 确认轮换并重启 frps，重启后仍使用旧 token（未重启 frpc 进程或未更新配置）的客户端将无法登录
+func confirmFrpsTokenRotationHandler(c *gin.Context) {
+	var req struct {
+		VersionID uint `json:"version_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	pendingTokenRotation.Lock()
+	defer pendingTokenRotation.Unlock()
+	if pendingTokenRotation.versionID == 0 || pendingTokenRotation.versionID != req.VersionID {
+		c.JSON(http.StatusConflict, gin.H{"error": "没有等待确认的 token 轮换"})
+		return
+	}
+
+	restart, err := utils.GetFrpsManager().Restart()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	pendingTokenRotation.versionID = 0
+	c.JSON(http.StatusOK, gin.H{"message": "frps 已重启并使用新 token，尚未重启 frpc 进程的客户端将无法重新连接", "restart": restart})
+}
@@ -8,8 +8,10 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"frp-admin/config"
@@ -18,6 +20,7 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
@@ -36,21 +39,35 @@ func main() {
 	// 初始化数据库
 	initDatabase()
 
+	// 托管的 frp 版本，需要在 frps 管理器之前确定可执行文件路径
+	initFrpBinaries()
+
 	// 初始化 frps 管理器
 	utils.InitFrpsManager(
 		config.AppConfig.FrpsPath,
 		config.AppConfig.FrpsConfig,
 		config.AppConfig.FrpsManager,
 		config.AppConfig.FrpsService,
+		config.AppConfig.DockerSocket,
+		utils.ProcessManagerOptions{
+			LogDir:      config.AppConfig.FrpsLogDir,
+			Supervise:   config.AppConfig.FrpsSupervise,
+			Detach:      config.AppConfig.FrpsDetach,
+			PIDFile:     config.AppConfig.FrpsPIDFile,
+			StopTimeout: time.Duration(config.AppConfig.FrpsStopTimeout) * time.Second,
+		},
 	)
 
+	// 定时检查客户端和代理的访问控制
+	go runAccessEnforcer()
+
 	// 设置 Gin
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
 
 	// CORS 配置
 	corsConfig := cors.Config{
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		MaxAge:           12 * time.Hour,
@@ -80,6 +97,10 @@ func main() {
 		// 公开路由
 		api.POST("/login", loginHandler)
 		api.GET("/health", healthHandler)
+		// frps 服务端插件回调（通过地址中的密钥校验）
+		api.POST("/frps/plugin/:key", frpsPluginHandler)
+		// 客户端安装包下载（通过签名地址校验，便于在目标机器上直接 curl）
+		api.GET("/bundles/:token", downloadClientBundleHandler)
 
 		// 需要认证的路由
 		auth := api.Group("")
@@ -90,6 +111,7 @@ func main() {
 
 			// frps 服务管理
 			auth.GET("/frps/status", frpsStatusHandler)
+			auth.GET("/frps/crashes", frpsCrashesHandler)
 			auth.POST("/frps/start", frpsStartHandler)
 			auth.POST("/frps/stop", frpsStopHandler)
 			auth.POST("/frps/restart", frpsRestartHandler)
@@ -97,7 +119,29 @@ func main() {
 			auth.POST("/frps/config", saveFrpsConfigHandler)
 			auth.POST("/frps/verify", verifyFrpsConfigHandler)
 			auth.GET("/frps/logs", getFrpsLogsHandler)
+			auth.GET("/frps/logs/query", queryFrpsLogsHandler)
+			auth.GET("/frps/logs/stream", streamFrpsLogsHandler)
 			auth.GET("/frps/parsed-config", getParsedFrpsConfigHandler)
+			auth.GET("/frps/plugin", getFrpsPluginHandler)
+			auth.POST("/frps/plugin/install", installFrpsPluginHandler)
+			auth.PUT("/frps/plugin/mode", setFrpsPluginModeHandler)
+			auth.GET("/frps/plugin/audits", getFrpsPluginAuditsHandler)
+			auth.POST("/frps/token/rotate", rotateFrpsTokenHandler)
+			auth.POST("/frps/token/rotate/confirm", confirmFrpsTokenRotationHandler)
+			auth.GET("/frps/settings", getFrpsSettingsHandler)
+			auth.PATCH("/frps/settings", patchFrpsSettingsHandler)
+
+			// frp 可执行文件版本
+			auth.GET("/frp/versions", getFrpVersionsHandler)
+			auth.POST("/frp/versions/upload", uploadFrpVersionHandler)
+			auth.POST("/frp/versions/:version/activate", activateFrpVersionHandler)
+			auth.DELETE("/frp/versions/:version", deleteFrpVersionHandler)
+
+			// frps 配置历史
+			auth.GET("/frps/config/versions", getFrpsConfigVersionsHandler)
+			auth.GET("/frps/config/versions/:id", getFrpsConfigVersionHandler)
+			auth.GET("/frps/config/diff", diffFrpsConfigVersionsHandler)
+			auth.POST("/frps/config/versions/:id/rollback", rollbackFrpsConfigHandler)
 
 			// frps Dashboard API 代理
 			auth.GET("/frps/dashboard/serverinfo", dashboardServerInfoHandler)
@@ -108,18 +152,37 @@ func main() {
 			auth.POST("/clients", createClientHandler)
 			auth.PUT("/clients/:id", updateClientHandler)
 			auth.DELETE("/clients/:id", deleteClientHandler)
+			auth.PUT("/clients/:id/access", updateClientAccessHandler)
 			auth.GET("/clients/:id/download", downloadClientConfigHandler)
+			auth.POST("/clients/:id/bundle", createClientBundleHandler)
+			auth.POST("/clients/:id/secret/rotate", rotateClientSecretHandler)
 
 			// frpc 在线管理
 			auth.GET("/clients/:id/frpc/status", frpcStatusHandler)
 			auth.POST("/clients/:id/frpc/reload", frpcReloadHandler)
 			auth.POST("/clients/:id/frpc/stop", frpcStopHandler)
+			auth.GET("/clients/:id/frpc/drift", frpcDriftHandler)
+			auth.GET("/clients/:id/frpc/preview", frpcPreviewHandler)
+			auth.POST("/clients/bulk/reload", bulkReloadHandler)
+			auth.GET("/jobs/:id", getBulkJobHandler)
+
+			// frpc 配置下发记录
+			auth.GET("/clients/:id/revisions", getFrpcRevisionsHandler)
+			auth.GET("/revisions/:id", getFrpcRevisionHandler)
 
 			// 代理管理
 			auth.GET("/clients/:id/proxies", getProxiesHandler)
 			auth.POST("/clients/:id/proxies", createProxyHandler)
 			auth.PUT("/proxies/:id", updateProxyHandler)
 			auth.DELETE("/proxies/:id", deleteProxyHandler)
+			auth.PUT("/proxies/:id/access", updateProxyAccessHandler)
+
+			// 负载均衡组
+			auth.GET("/proxy-groups", getProxyGroupsHandler)
+			auth.POST("/proxy-groups", createProxyGroupHandler)
+			auth.PUT("/proxy-groups/:id", updateProxyGroupHandler)
+			auth.DELETE("/proxy-groups/:id", deleteProxyGroupHandler)
+			auth.GET("/proxy-groups/:id/status", proxyGroupStatusHandler)
 
 			// 访问者管理
 			auth.GET("/clients/:id/visitors", getVisitorsHandler)
@@ -157,7 +220,10 @@ func initDatabase() {
 	}
 
 	// 自动迁移
-	db.AutoMigrate(&models.User{}, &models.FrpcConfig{}, &models.Proxy{}, &models.Visitor{}, &models.Setting{})
+	db.AutoMigrate(&models.User{}, &models.FrpcConfig{}, &models.Proxy{}, &models.Visitor{}, &models.Setting{}, &models.FrpsConfigVersion{}, &models.FrpcConfigRevision{}, &models.ProxyGroup{}, &models.FrpsPluginAudit{})
+	ensureClientAuthSecrets()
+	// 早期版本软删除的负载均衡组仍占用唯一的组名，启动时清理
+	db.Unscoped().Where("deleted_at IS NOT NULL").Delete(&models.ProxyGroup{})
 
 	// 创建默认管理员账户
 	var count int64
@@ -293,6 +359,7 @@ func jwtMiddleware() gin.HandlerFunc {
 
 		c.Set("user_id", claims["user_id"])
 		c.Set("username", claims["username"])
+		c.Set("token_exp", claims["exp"])
 		c.Next()
 	}
 }
@@ -424,6 +491,16 @@ func frpsStatusHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, manager.Status())
 }
 
+// frpsCrashesHandler 进程模式下最近的意外退出记录
+func frpsCrashesHandler(c *gin.Context) {
+	pm, ok := utils.GetFrpsManager().(*utils.ProcessManager)
+	if !ok {
+		c.JSON(http.StatusOK, []utils.FrpsCrash{})
+		return
+	}
+	c.JSON(http.StatusOK, pm.Crashes())
+}
+
 func frpsStartHandler(c *gin.Context) {
 	manager := utils.GetFrpsManager()
 	if err := manager.Start(); err != nil {
@@ -435,20 +512,22 @@ func frpsStartHandler(c *gin.Context) {
 
 func frpsStopHandler(c *gin.Context) {
 	manager := utils.GetFrpsManager()
-	if err := manager.Stop(); err != nil {
+	result, err := manager.Stop()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "frps stopped successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "frps stopped successfully", "result": result})
 }
 
 func frpsRestartHandler(c *gin.Context) {
 	manager := utils.GetFrpsManager()
-	if err := manager.Restart(); err != nil {
+	result, err := manager.Restart()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "frps restarted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "frps restarted successfully", "result": result})
 }
 
 func getFrpsConfigHandler(c *gin.Context) {
@@ -470,29 +549,87 @@ func saveFrpsConfigHandler(c *gin.Context) {
 		return
 	}
 
-	// 先写入临时文件验证（使用 0600 权限保护敏感信息）
-	tmpFile := config.AppConfig.FrpsConfig + ".tmp"
-	if err := os.WriteFile(tmpFile, []byte(req.Config), 0600); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write config"})
+	version, status, err := saveFrpsConfig(req.Config, c.GetString("username"), "save")
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"message": "Config saved successfully", "version_id": version.ID})
+}
+
+// frpsConfigMu 串行化 frps.toml 的读取、修改、写入和历史记录，避免并发修改互相覆盖
+var frpsConfigMu sync.Mutex
+
+// saveFrpsConfig 验证并保存 frps 配置，每次保存都会记录一个历史版本
+// 失败时返回对应的 HTTP 状态码
+func saveFrpsConfig(content, author, source string) (*models.FrpsConfigVersion, int, error) {
+	frpsConfigMu.Lock()
+	defer frpsConfigMu.Unlock()
+	return writeFrpsConfig(content, author, source)
+}
+
+// updateFrpsConfig 读取当前 frps 配置，由 modify 生成新内容后保存，整个过程持有 frpsConfigMu
+// modify 返回错误时使用它给出的 HTTP 状态码
+func updateFrpsConfig(author, source string, modify func(current string) (string, int, error)) (*models.FrpsConfigVersion, int, error) {
+	frpsConfigMu.Lock()
+	defer frpsConfigMu.Unlock()
+
+	data, err := os.ReadFile(config.AppConfig.FrpsConfig)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to read config file")
+	}
+	content, status, err := modify(string(data))
+	if err != nil {
+		return nil, status, err
+	}
+	return writeFrpsConfig(content, author, source)
+}
+
+// writeFrpsConfig 执行实际的验证、写入和历史记录，调用方需持有 frpsConfigMu
+func writeFrpsConfig(content, author, source string) (*models.FrpsConfigVersion, int, error) {
+	recordInitialFrpsConfigVersion()
+
+	version := &models.FrpsConfigVersion{
+		Content: content,
+		Author:  author,
+		Source:  source,
+	}
+
+	// 先在配置文件所在目录创建临时文件验证（CreateTemp 使用 0600 权限保护敏感信息）
+	tmpFile, err := os.CreateTemp(filepath.Dir(config.AppConfig.FrpsConfig), "frps_save_*.toml")
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to create temp file")
+	}
+	tmpFileName := tmpFile.Name()
+	defer os.Remove(tmpFileName)
+	_, err = tmpFile.WriteString(content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to write config")
+	}
+
 	// 验证配置
 	manager := utils.GetFrpsManager()
-	if err := manager.Verify(tmpFile); err != nil {
-		os.Remove(tmpFile)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if err := manager.Verify(tmpFileName); err != nil {
+		version.VerifyError = err.Error()
+		db.Create(version)
+		return version, http.StatusBadRequest, err
 	}
 
 	// 验证通过，替换原文件
-	os.Remove(tmpFile)
-	if err := os.WriteFile(config.AppConfig.FrpsConfig, []byte(req.Config), 0644); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config"})
-		return
+	if err := os.WriteFile(config.AppConfig.FrpsConfig, []byte(content), 0644); err != nil {
+		// 验证通过但未能写入，同样记录这次尝试，避免历史中丢失
+		version.VerifyError = "写入配置文件失败: " + err.Error()
+		db.Create(version)
+		return version, http.StatusInternalServerError, fmt.Errorf("Failed to save config: %v", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Config saved successfully"})
+	version.Verified = true
+	db.Create(version)
+	return version, http.StatusOK, nil
 }
 
 func verifyFrpsConfigHandler(c *gin.Context) {
@@ -531,20 +668,70 @@ func verifyFrpsConfigHandler(c *gin.Context) {
 }
 
 func getFrpsLogsHandler(c *gin.Context) {
+	lines := 100
+	if v, err := strconv.Atoi(c.Query("lines")); err == nil && v > 0 {
+		lines = v
+	}
 	manager := utils.GetFrpsManager()
-	logs := manager.GetLogs(100)
+	logs := manager.GetLogs(lines)
 	c.JSON(http.StatusOK, gin.H{"logs": logs})
 }
 
 func getParsedFrpsConfigHandler(c *gin.Context) {
 	frpsConfig, err := utils.ParseFrpsToml(config.AppConfig.FrpsConfig)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse frps config"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse frps config: " + err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, frpsConfig)
 }
 
+// 获取可结构化编辑的 frps 配置项
+func getFrpsSettingsHandler(c *gin.Context) {
+	data, err := os.ReadFile(config.AppConfig.FrpsConfig)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read config file"})
+		return
+	}
+
+	settings, err := utils.GetFrpsSettings(string(data))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"settings": settings,
+		"keys":     utils.FrpsSettingKeys(),
+	})
+}
+
+// 修改部分 frps 配置项，保留配置文件中的注释和顺序
+// 请求体为 {"bindPort": 7001, "auth.token": null}，null 表示删除该项
+func patchFrpsSettingsHandler(c *gin.Context) {
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	version, status, err := updateFrpsConfig(c.GetString("username"), "settings", func(current string) (string, int, error) {
+		content, err := utils.ApplyFrpsSettings(current, req)
+		return content, http.StatusBadRequest, err
+	})
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, _ := utils.GetFrpsSettings(version.Content)
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Config saved successfully",
+		"version_id": version.ID,
+		"settings":   settings,
+	})
+}
+
 // ============= frps Dashboard API 代理 =============
 
 func getDashboardClient() *utils.DashboardClient {
@@ -555,10 +742,10 @@ func getDashboardClient() *utils.DashboardClient {
 		return utils.NewDashboardClient("127.0.0.1", 7500, "admin", "admin")
 	}
 	return utils.NewDashboardClient(
-		frpsConfig.WebServerAddr,
-		frpsConfig.WebServerPort,
-		frpsConfig.WebServerUser,
-		frpsConfig.WebServerPass,
+		frpsConfig.WebServer.Addr,
+		frpsConfig.WebServer.Port,
+		frpsConfig.WebServer.User,
+		frpsConfig.WebServer.Password,
 	)
 }
 
@@ -609,6 +796,17 @@ func createClientHandler(c *gin.Context) {
 		}
 	}
 
+	if err := validateClientFrpcOptions(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := utils.ParseAccessWindows(req.AccessWindows); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.AuthSecret = config.GenerateRandomToken()
+	req.ApplyStatus, req.ApplyError, req.AppliedAt = "", "", nil
 	if err := db.Create(&req).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
 		return
@@ -631,6 +829,11 @@ func updateClientHandler(c *gin.Context) {
 		return
 	}
 
+	if err := validateClientFrpcOptions(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 处理管理配置
 	adminPort := req.AdminPort
 	adminRemotePort := req.AdminRemotePort
@@ -657,6 +860,25 @@ func updateClientHandler(c *gin.Context) {
 		"admin_user":        req.AdminUser,
 		"admin_pass":        req.AdminPass,
 		"admin_remote_port": adminRemotePort,
+		"auto_apply":        req.AutoApply,
+		// frpc 选项
+		"transport_protocol":                req.TransportProtocol,
+		"dial_server_timeout":               req.DialServerTimeout,
+		"proxy_url":                         req.ProxyURL,
+		"pool_count":                        req.PoolCount,
+		"tcp_mux":                           req.TCPMux,
+		"heartbeat_interval":                req.HeartbeatInterval,
+		"heartbeat_timeout":                 req.HeartbeatTimeout,
+		"tls_enable":                        req.TLSEnable,
+		"tls_cert_file":                     req.TLSCertFile,
+		"tls_key_file":                      req.TLSKeyFile,
+		"tls_trusted_ca_file":               req.TLSTrustedCaFile,
+		"tls_server_name":                   req.TLSServerName,
+		"tls_disable_custom_tls_first_byte": req.TLSDisableCustomTLSFirstByte,
+		"login_fail_exit":                   req.LoginFailExit,
+		"log_to":                            req.LogTo,
+		"log_level":                         req.LogLevel,
+		"log_max_days":                      req.LogMaxDays,
 	})
 
 	// 重新加载更新后的数据
@@ -712,6 +934,8 @@ func deleteClientHandler(c *gin.Context) {
 	db.Where("frpc_config_id = ?", id).Delete(&models.Proxy{})
 	// 删除关联的访问者
 	db.Where("frpc_config_id = ?", id).Delete(&models.Visitor{})
+	// 删除配置下发记录
+	db.Where("frpc_config_id = ?", id).Delete(&models.FrpcConfigRevision{})
 
 	// 删除客户端配置
 	if err := db.Delete(&models.FrpcConfig{}, id).Error; err != nil {
@@ -741,7 +965,7 @@ func generateClientToml(client *models.FrpcConfig) (string, error) {
 	frpsConfig, _ := utils.ParseFrpsToml(config.AppConfig.FrpsConfig)
 	authToken := "your-token"
 	if frpsConfig != nil {
-		authToken = frpsConfig.AuthToken
+		authToken = frpsConfig.Auth.Token
 	}
 
 	// 服务器公网地址和端口从设置中读取
@@ -751,6 +975,21 @@ func generateClientToml(client *models.FrpcConfig) (string, error) {
 		serverPort = frpsConfig.BindPort
 	}
 
+	// KCP/QUIC 连接 frps 的 UDP 端口
+	options := resolveFrpcOptions(client)
+	if frpsConfig != nil {
+		switch options.TransportProtocol {
+		case "kcp":
+			if frpsConfig.KCPBindPort > 0 {
+				serverPort = frpsConfig.KCPBindPort
+			}
+		case "quic":
+			if frpsConfig.QUICBindPort > 0 {
+				serverPort = frpsConfig.QUICBindPort
+			}
+		}
+	}
+
 	var settings []models.Setting
 	db.Where("key IN ?", []string{"server_addr", "server_port"}).Find(&settings)
 	for _, s := range settings {
@@ -758,15 +997,40 @@ func generateClientToml(client *models.FrpcConfig) (string, error) {
 		case "server_addr":
 			serverAddr = s.Value
 		case "server_port":
+			// 设置的是 frps 的 TCP 端口，KCP/QUIC 不使用
+			if options.TransportProtocol == "kcp" || options.TransportProtocol == "quic" {
+				continue
+			}
 			if v, err := strconv.Atoi(s.Value); err == nil {
 				serverPort = v
 			}
 		}
 	}
 
+	// 加载代理所属的负载均衡组
+	groups := map[uint]models.ProxyGroup{}
+	var groupIDs []uint
+	for _, p := range client.Proxies {
+		if p.ProxyGroupID != nil {
+			groupIDs = append(groupIDs, *p.ProxyGroupID)
+		}
+	}
+	if len(groupIDs) > 0 {
+		var groupList []models.ProxyGroup
+		db.Where("id IN ?", groupIDs).Find(&groupList)
+		for _, g := range groupList {
+			groups[g.ID] = g
+		}
+	}
+
 	// 构建代理配置
 	var proxies []utils.ProxyConfig
+	now := time.Now()
 	for _, p := range client.Proxies {
+		// 已禁用或已过期的代理不生成
+		if proxyRemoved(&p, now) {
+			continue
+		}
 		proxy := utils.ProxyConfig{
 			Name:                       p.Name,
 			Type:                       p.Type,
@@ -775,6 +1039,13 @@ func generateClientToml(client *models.FrpcConfig) (string, error) {
 			RemotePort:                 p.RemotePort,
 			SecretKey:                  p.SecretKey,
 			AllowUsers:                 p.AllowUsers,
+			CustomDomains:              p.CustomDomains,
+			Subdomain:                  p.Subdomain,
+			Locations:                  p.Locations,
+			HostHeaderRewrite:          p.HostHeaderRewrite,
+			HTTPUser:                   p.HTTPUser,
+			HTTPPassword:               p.HTTPPassword,
+			RouteByHTTPUser:            p.RouteByHTTPUser,
 			BandwidthLimit:             p.BandwidthLimit,
 			BandwidthLimitMode:         p.BandwidthLimitMode,
 			UseEncryption:              p.UseEncryption,
@@ -792,6 +1063,32 @@ func generateClientToml(client *models.FrpcConfig) (string, error) {
 				proxy.PluginParams = pluginParams
 			}
 		}
+		if p.RequestHeaders != "" {
+			var headers map[string]string
+			if err := json.Unmarshal([]byte(p.RequestHeaders), &headers); err == nil {
+				proxy.RequestHeaders = headers
+			}
+		}
+		if p.ResponseHeaders != "" {
+			var headers map[string]string
+			if err := json.Unmarshal([]byte(p.ResponseHeaders), &headers); err == nil {
+				proxy.ResponseHeaders = headers
+			}
+		}
+		extra, err := utils.ParseProxyExtraConfig(p.ExtraConfig)
+		if err != nil {
+			return "", fmt.Errorf("代理 %s 的%v", p.Name, err)
+		}
+		proxy.ExtraConfig = extra
+		if p.ProxyGroupID != nil {
+			if g, ok := groups[*p.ProxyGroupID]; ok {
+				proxy.LoadBalancerGroup = g.Name
+				proxy.LoadBalancerGroupKey = g.GroupKey
+				if g.Type == "tcp" {
+					proxy.RemotePort = g.RemotePort
+				}
+			}
+		}
 		proxies = append(proxies, proxy)
 	}
 
@@ -843,11 +1140,13 @@ func generateClientToml(client *models.FrpcConfig) (string, error) {
 		AdminUser:       client.AdminUser,
 		AdminPass:       client.AdminPass,
 		AdminRemotePort: client.AdminRemotePort,
+		Options:         options,
+		Metadatas:       map[string]string{utils.FrpcAuthSecretMetaKey: client.AuthSecret},
 		Proxies:         proxies,
 		Visitors:        visitors,
 	}
 
-	return utils.GenerateFrpcToml(tomlConfig), nil
+	return utils.GenerateFrpcToml(tomlConfig)
 }
 
 func frpcStatusHandler(c *gin.Context) {
@@ -887,26 +1186,65 @@ func frpcReloadHandler(c *gin.Context) {
 		return
 	}
 
-	// 生成最新的配置
-	tomlContent, err := generateClientToml(&client)
+	// 预览校验和推送在同一次加锁中完成，避免期间被其他推送改变
+	defer lockClientApply(client.ID)()
+	// 与自动应用一致，只推送当前允许访问的代理
+	pushed := allowedProxiesClient(&client, time.Now())
+
+	// 带有预览时的 expected_revision 时，配置或推送记录已变化则拒绝重载
+	var req struct {
+		ExpectedRevision string `json:"expected_revision"`
+	}
+	c.ShouldBindJSON(&req)
+	if req.ExpectedRevision != "" {
+		generated, err := generateClientToml(pushed)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成配置失败: " + err.Error()})
+			return
+		}
+		if current := previewRevision(client.ID, generated); current != req.ExpectedRevision {
+			c.JSON(http.StatusConflict, gin.H{"error": "预览已过期，请重新预览后再重载", "current_revision": current})
+			return
+		}
+	}
+
+	// 推送后检查代理状态，失败时恢复推送前的配置
+	result, err := applyWithRollback(frpcClient, pushed, c.GetString("username"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成配置失败: " + err.Error()})
+		resp := gin.H{"error": err.Error()}
+		if result != nil {
+			resp["rollback"] = result
+		}
+		c.JSON(http.StatusInternalServerError, resp)
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"message": "配置已推送并重载"})
+}
+
+// pushClientConfig 生成最新的配置，推送到 frpc 并重载
+func pushClientConfig(frpcClient *utils.FrpcClient, client *models.FrpcConfig, author string) error {
+	// 生成最新的配置
+	tomlContent, err := generateClientToml(client)
+	if err != nil {
+		return fmt.Errorf("生成配置失败: %v", err)
+	}
+	return pushTomlConfig(frpcClient, client, tomlContent, "push", author)
+}
+
+// pushTomlConfig 推送指定的配置内容到 frpc 并重载，action 记录到下发记录中
+func pushTomlConfig(frpcClient *utils.FrpcClient, client *models.FrpcConfig, tomlContent, action, author string) error {
 	// 推送配置到 frpc
 	if err := frpcClient.UpdateConfig(tomlContent); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "推送配置失败: " + err.Error()})
-		return
+		return fmt.Errorf("推送配置失败: %v", err)
 	}
+	recordFrpcRevision(client, tomlContent, action, author)
 
 	// 重载配置
 	if err := frpcClient.Reload(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "重载失败: " + err.Error()})
-		return
+		return fmt.Errorf("重载失败: %v", err)
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "配置已推送并重载"})
+	return nil
 }
 
 func frpcStopHandler(c *gin.Context) {
@@ -923,7 +1261,10 @@ func frpcStopHandler(c *gin.Context) {
 		return
 	}
 
-	if err := frpcClient.Stop(); err != nil {
+	unlock := lockClientApply(client.ID)
+	err = frpcClient.Stop()
+	unlock()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "停止失败: " + err.Error()})
 		return
 	}
@@ -945,6 +1286,7 @@ func downloadClientConfigHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成配置失败: " + err.Error()})
 		return
 	}
+	recordFrpcRevision(&client, content, "download", c.GetString("username"))
 
 	// 设置下载头
 	filename := fmt.Sprintf("frpc_%s.toml", client.User)
@@ -977,6 +1319,11 @@ func createProxyHandler(c *gin.Context) {
 		return
 	}
 
+	if err := validateProxy(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 检查同一客户端下代理名称是否重复
 	var existingProxy models.Proxy
 	if err := db.Where("frpc_config_id = ? AND name = ?", client.ID, req.Name).First(&existingProxy).Error; err == nil {
@@ -997,6 +1344,7 @@ func createProxyHandler(c *gin.Context) {
 		return
 	}
 
+	scheduleAutoApply(client.ID, c.GetString("username"))
 	c.JSON(http.StatusOK, req)
 }
 
@@ -1009,10 +1357,34 @@ func updateProxyHandler(c *gin.Context) {
 	}
 
 	var req models.Proxy
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
+	// proxy_group_id 为空指针时无法区分未提交和退出分组，需要检查原始字段
+	var fields map[string]json.RawMessage
+	c.ShouldBindBodyWith(&fields, binding.JSON)
+	_, groupSubmitted := fields["proxy_group_id"]
+
+	// 未提交的字段沿用原值后再校验
+	req.ID = proxy.ID
+	if req.Type == "" {
+		req.Type = proxy.Type
+	}
+	if !groupSubmitted {
+		req.ProxyGroupID = proxy.ProxyGroupID
+	} else if req.ProxyGroupID == nil && proxy.ProxyGroupID != nil && req.RemotePort == 0 {
+		// 退出分组但未指定新端口时仍会沿用组的共享端口，需要参与冲突检查
+		req.RemotePort = proxy.RemotePort
+	}
+	if req.CustomDomains == "" && req.Subdomain == "" {
+		req.CustomDomains = proxy.CustomDomains
+		req.Subdomain = proxy.Subdomain
+	}
+	if err := validateProxy(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 如果名称变更，检查是否重复
 	if req.Name != "" && req.Name != proxy.Name {
@@ -1029,16 +1401,90 @@ func updateProxyHandler(c *gin.Context) {
 		}
 	}
 
-	db.Model(&proxy).Updates(req)
+	// 访问控制通过单独的接口修改
+	db.Model(&proxy).Omit("disabled", "expires_at", "access_windows").Updates(req)
+	// Updates 会忽略空指针，显式提交时单独更新以支持退出负载均衡组
+	if groupSubmitted {
+		db.Model(&proxy).Update("proxy_group_id", req.ProxyGroupID)
+	}
+	scheduleAutoApply(proxy.FrpcConfigID, c.GetString("username"))
 	c.JSON(http.StatusOK, proxy)
 }
 
+// validateProxy 校验代理类型、额外配置及其依赖的 frps 配置
+func validateProxy(p *models.Proxy) error {
+	if _, err := utils.ParseProxyExtraConfig(p.ExtraConfig); err != nil {
+		return err
+	}
+	if _, err := utils.ParseAccessWindows(p.AccessWindows); err != nil {
+		return err
+	}
+
+	switch p.Type {
+	case "tcp", "udp", "http", "https", "tcpmux", "stcp", "xtcp", "sudp":
+	default:
+		return fmt.Errorf("不支持的代理类型: %s", p.Type)
+	}
+
+	if err := applyProxyGroup(p); err != nil {
+		return err
+	}
+	if err := checkRemotePortConflict(p); err != nil {
+		return err
+	}
+
+	switch p.Type {
+	case "http", "https", "tcpmux":
+		return validateVhostProxy(p)
+	}
+	return nil
+}
+
+// validateVhostProxy 校验 HTTP/HTTPS/TCPMUX 代理依赖的 frps 虚拟主机配置
+func validateVhostProxy(p *models.Proxy) error {
+	frpsConfig, err := utils.ParseFrpsToml(config.AppConfig.FrpsConfig)
+	if err != nil {
+		return fmt.Errorf("读取 frps 配置失败: %v", err)
+	}
+	if p.Type == "http" && frpsConfig.VhostHTTPPort <= 0 {
+		return fmt.Errorf("frps 未配置 vhostHTTPPort，无法使用 HTTP 代理")
+	}
+	if p.Type == "https" && frpsConfig.VhostHTTPSPort <= 0 {
+		return fmt.Errorf("frps 未配置 vhostHTTPSPort，无法使用 HTTPS 代理")
+	}
+	if p.Type == "tcpmux" && frpsConfig.TCPMuxHTTPConnectPort <= 0 {
+		return fmt.Errorf("frps 未配置 tcpmuxHTTPConnectPort，无法使用 TCPMUX 代理")
+	}
+	if strings.TrimSpace(p.CustomDomains) == "" && p.Subdomain == "" {
+		return fmt.Errorf("HTTP/HTTPS/TCPMUX 代理需要设置自定义域名或子域名")
+	}
+	if p.Subdomain != "" && frpsConfig.SubDomainHost == "" {
+		return fmt.Errorf("frps 未配置 subDomainHost，无法使用子域名")
+	}
+	for _, headers := range []string{p.RequestHeaders, p.ResponseHeaders} {
+		if headers == "" {
+			continue
+		}
+		var m map[string]string
+		if err := json.Unmarshal([]byte(headers), &m); err != nil {
+			return fmt.Errorf("请求头/响应头格式错误，应为 JSON 对象: %v", err)
+		}
+	}
+	return nil
+}
+
 func deleteProxyHandler(c *gin.Context) {
 	id := c.Param("id")
-	if err := db.Delete(&models.Proxy{}, id).Error; err != nil {
+	var proxy models.Proxy
+	if err := db.First(&proxy, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Proxy not found"})
+		return
+	}
+	if err := db.Delete(&proxy).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete proxy"})
 		return
 	}
+	scheduleAutoApply(proxy.FrpcConfigID, c.GetString("username"))
 	c.JSON(http.StatusOK, gin.H{"message": "Proxy deleted successfully"})
 }
 
@@ -1086,6 +1532,7 @@ func createVisitorHandler(c *gin.Context) {
 		return
 	}
 
+	scheduleAutoApply(client.ID, c.GetString("username"))
 	c.JSON(http.StatusOK, req)
 }
 
@@ -1119,15 +1566,22 @@ func updateVisitorHandler(c *gin.Context) {
 	}
 
 	db.Model(&visitor).Updates(req)
+	scheduleAutoApply(visitor.FrpcConfigID, c.GetString("username"))
 	c.JSON(http.StatusOK, visitor)
 }
 
 func deleteVisitorHandler(c *gin.Context) {
 	id := c.Param("id")
-	if err := db.Delete(&models.Visitor{}, id).Error; err != nil {
+	var visitor models.Visitor
+	if err := db.First(&visitor, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visitor not found"})
+		return
+	}
+	if err := db.Delete(&visitor).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete visitor"})
 		return
 	}
+	scheduleAutoApply(visitor.FrpcConfigID, c.GetString("username"))
 	c.JSON(http.StatusOK, gin.H{"message": "Visitor deleted successfully"})
 }
 
@@ -1187,6 +1641,30 @@ func saveSettingsHandler(c *gin.Context) {
 		return
 	}
 
+	// 校验 frpc 默认选项，未提交的项沿用已保存的值
+	frpcValues := make(map[string]string)
+	var saved []models.Setting
+	db.Where("key IN ?", frpcOptionSettingKeys).Find(&saved)
+	for _, s := range saved {
+		frpcValues[s.Key] = s.Value
+	}
+	for _, key := range frpcOptionSettingKeys {
+		if v, ok := req[key]; ok {
+			frpcValues[key] = v
+		}
+	}
+	if _, err := frpcOptionsFromSettings(frpcValues); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "frpc 默认选项无效: " + err.Error()})
+		return
+	}
+
+	if tz := req[accessTimezoneSetting]; tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时区: " + tz})
+			return
+		}
+	}
+
 	for key, value := range req {
 		db.Where("key = ?", key).Assign(models.Setting{Value: value}).FirstOrCreate(&models.Setting{Key: key})
 	}
@@ -1213,15 +1691,22 @@ func getAvailablePortsHandler(c *gin.Context) {
 		}
 	}
 
-	// 获取已使用的端口
-	var usedPorts []int
+	// 获取已使用的端口（负载均衡组内的代理共享端口，只计一次）
+	var proxyPorts, groupPorts []int
 	db.Model(&models.Proxy{}).
 		Where("type IN ? AND remote_port > 0", []string{"tcp", "udp"}).
-		Pluck("remote_port", &usedPorts)
+		Pluck("remote_port", &proxyPorts)
+	db.Model(&models.ProxyGroup{}).
+		Where("type = ? AND remote_port > 0", "tcp").
+		Pluck("remote_port", &groupPorts)
 
 	usedPortMap := make(map[int]bool)
-	for _, p := range usedPorts {
-		usedPortMap[p] = true
+	var usedPorts []int
+	for _, p := range append(proxyPorts, groupPorts...) {
+		if !usedPortMap[p] {
+			usedPortMap[p] = true
+			usedPorts = append(usedPorts, p)
+		}
 	}
 
 	// 生成可用端口列表（最多返回100个）
@@ -1239,12 +1724,14 @@ func getAvailablePortsHandler(c *gin.Context) {
 		ProxyType  string `json:"proxy_type"`
 		ClientName string `json:"client_name"`
 		ClientUser string `json:"client_user"`
+		GroupName  string `json:"group_name"`
 	}
 	var portUsages []PortUsage
 
 	db.Table("proxies").
-		Select("proxies.remote_port as port, proxies.name as proxy_name, proxies.type as proxy_type, frpc_configs.name as client_name, frpc_configs.user as client_user").
+		Select("proxies.remote_port as port, proxies.name as proxy_name, proxies.type as proxy_type, frpc_configs.name as client_name, frpc_configs.user as client_user, proxy_groups.name as group_name").
 		Joins("LEFT JOIN frpc_configs ON proxies.frpc_config_id = frpc_configs.id").
+		Joins("LEFT JOIN proxy_groups ON proxies.proxy_group_id = proxy_groups.id AND proxy_groups.deleted_at IS NULL").
 		Where("proxies.type IN ? AND proxies.remote_port > 0 AND proxies.deleted_at IS NULL AND frpc_configs.deleted_at IS NULL", []string{"tcp", "udp"}).
 		Order("proxies.remote_port").
 		Scan(&portUsages)
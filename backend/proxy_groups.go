@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"frp-admin/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============= 负载均衡组 Handler =============
+
+// frpc 支持 loadBalancer 的代理类型
+var proxyGroupTypes = map[string]bool{"tcp": true, "http": true, "tcpmux": true}
+
+// applyProxyGroup 校验代理加入的负载均衡组，tcp 组的代理统一使用组的远程端口
+func applyProxyGroup(p *models.Proxy) error {
+	if p.ProxyGroupID == nil {
+		return nil
+	}
+	var group models.ProxyGroup
+	if err := db.First(&group, *p.ProxyGroupID).Error; err != nil {
+		return fmt.Errorf("负载均衡组不存在")
+	}
+	if group.Type != p.Type {
+		return fmt.Errorf("负载均衡组 %s 仅允许 %s 类型的代理加入", group.Name, group.Type)
+	}
+	if group.Type == "tcp" {
+		p.RemotePort = group.RemotePort
+	}
+	return nil
+}
+
+// checkRemotePortConflict 检查 tcp/udp 代理的远程端口是否已被占用
+// 同一负载均衡组内的代理共享端口，不视为冲突
+func checkRemotePortConflict(p *models.Proxy) error {
+	if (p.Type != "tcp" && p.Type != "udp") || p.RemotePort <= 0 {
+		return nil
+	}
+
+	query := db.Model(&models.Proxy{}).Where("type = ? AND remote_port = ? AND id != ?", p.Type, p.RemotePort, p.ID)
+	if p.ProxyGroupID != nil {
+		query = query.Where("proxy_group_id IS NULL OR proxy_group_id != ?", *p.ProxyGroupID)
+	}
+	var existing models.Proxy
+	if err := query.First(&existing).Error; err == nil {
+		return fmt.Errorf("远程端口 %d 已被代理 %s 使用", p.RemotePort, existing.Name)
+	}
+
+	if p.Type != "tcp" {
+		return nil
+	}
+	return checkTCPPortReserved(p.RemotePort, p.ProxyGroupID)
+}
+
+// checkTCPPortReserved 检查 tcp 端口是否已被其他负载均衡组或客户端管理端口占用
+func checkTCPPortReserved(port int, groupID *uint) error {
+	groupQuery := db.Model(&models.ProxyGroup{}).Where("type = ? AND remote_port = ?", "tcp", port)
+	if groupID != nil {
+		groupQuery = groupQuery.Where("id != ?", *groupID)
+	}
+	var group models.ProxyGroup
+	if err := groupQuery.First(&group).Error; err == nil {
+		return fmt.Errorf("远程端口 %d 已被负载均衡组 %s 使用", port, group.Name)
+	}
+
+	var client models.FrpcConfig
+	if err := db.Where("admin_remote_port = ?", port).First(&client).Error; err == nil {
+		return fmt.Errorf("远程端口 %d 已被客户端 %s 的管理端口使用", port, client.Name)
+	}
+	return nil
+}
+
+// validateProxyGroup 校验组类型和 tcp 组的共享端口
+func validateProxyGroup(g *models.ProxyGroup) error {
+	if g.Name == "" {
+		return fmt.Errorf("组名不能为空")
+	}
+	if !proxyGroupTypes[g.Type] {
+		return fmt.Errorf("负载均衡组仅支持 tcp、http、tcpmux 类型")
+	}
+	if g.Type != "tcp" {
+		g.RemotePort = 0
+		return nil
+	}
+	if g.RemotePort <= 0 || g.RemotePort > 65535 {
+		return fmt.Errorf("tcp 负载均衡组需要设置远程端口")
+	}
+
+	// 端口不能被组外的代理占用
+	query := db.Model(&models.Proxy{}).Where("type = ? AND remote_port = ?", "tcp", g.RemotePort)
+	if g.ID != 0 {
+		query = query.Where("proxy_group_id IS NULL OR proxy_group_id != ?", g.ID)
+	}
+	var existing models.Proxy
+	if err := query.First(&existing).Error; err == nil {
+		return fmt.Errorf("远程端口 %d 已被代理 %s 使用", g.RemotePort, existing.Name)
+	}
+
+	var groupID *uint
+	if g.ID != 0 {
+		groupID = &g.ID
+	}
+	return checkTCPPortReserved(g.RemotePort, groupID)
+}
+
+func getProxyGroupsHandler(c *gin.Context) {
+	var groups []models.ProxyGroup
+	db.Order("id").Find(&groups)
+
+	type groupCount struct {
+		ProxyGroupID uint
+		Count        int
+	}
+	var counts []groupCount
+	db.Model(&models.Proxy{}).
+		Select("proxy_group_id, count(*) as count").
+		Where("proxy_group_id IS NOT NULL").
+		Group("proxy_group_id").
+		Scan(&counts)
+	countMap := make(map[uint]int)
+	for _, item := range counts {
+		countMap[item.ProxyGroupID] = item.Count
+	}
+
+	type groupItem struct {
+		models.ProxyGroup
+		MemberCount int `json:"member_count"`
+	}
+	result := make([]groupItem, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, groupItem{ProxyGroup: g, MemberCount: countMap[g.ID]})
+	}
+	c.JSON(http.StatusOK, gin.H{"groups": result})
+}
+
+func createProxyGroupHandler(c *gin.Context) {
+	var req models.ProxyGroup
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	req.ID = 0
+
+	if err := validateProxyGroup(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var existing models.ProxyGroup
+	if err := db.Where("name = ?", req.Name).First(&existing).Error; err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "组名已存在，请使用其他名称"})
+		return
+	}
+
+	if err := db.Create(&req).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create proxy group"})
+		return
+	}
+	c.JSON(http.StatusOK, req)
+}
+
+func updateProxyGroupHandler(c *gin.Context) {
+	id := c.Param("id")
+	var group models.ProxyGroup
+	if err := db.First(&group, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Proxy group not found"})
+		return
+	}
+
+	var req models.ProxyGroup
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	req.ID = group.ID
+
+	// 已有成员时不允许修改类型
+	var memberCount int64
+	db.Model(&models.Proxy{}).Where("proxy_group_id = ?", group.ID).Count(&memberCount)
+	if memberCount > 0 && req.Type != group.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "组内已有代理，无法修改类型"})
+		return
+	}
+
+	if err := validateProxyGroup(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name != group.Name {
+		var existing models.ProxyGroup
+		if err := db.Where("name = ? AND id != ?", req.Name, group.ID).First(&existing).Error; err == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "组名已存在，请使用其他名称"})
+			return
+		}
+	}
+
+	db.Model(&group).Updates(map[string]interface{}{
+		"name":        req.Name,
+		"group_key":   req.GroupKey,
+		"type":        req.Type,
+		"remote_port": req.RemotePort,
+		"remark":      req.Remark,
+	})
+
+	// 同步 tcp 组成员的远程端口
+	db.First(&group, id)
+	if group.Type == "tcp" {
+		db.Model(&models.Proxy{}).Where("proxy_group_id = ?", group.ID).Update("remote_port", group.RemotePort)
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+func deleteProxyGroupHandler(c *gin.Context) {
+	id := c.Param("id")
+	var group models.ProxyGroup
+	if err := db.First(&group, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Proxy group not found"})
+		return
+	}
+
+	// 成员共享同一端口，直接解散会导致端口冲突，要求先移出所有代理
+	var memberCount int64
+	db.Model(&models.Proxy{}).Where("proxy_group_id = ?", group.ID).Count(&memberCount)
+	if memberCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("组内还有 %d 个代理，请先将其移出", memberCount)})
+		return
+	}
+
+	// 组名有唯一索引，软删除的行会一直占用组名，因此直接物理删除
+	if err := db.Unscoped().Delete(&group).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete proxy group"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Proxy group deleted successfully"})
+}
+
+// 列出组成员及其在 frps 上的实时状态
+func proxyGroupStatusHandler(c *gin.Context) {
+	id := c.Param("id")
+	var group models.ProxyGroup
+	if err := db.First(&group, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Proxy group not found"})
+		return
+	}
+
+	var proxies []models.Proxy
+	db.Where("proxy_group_id = ?", group.ID).Find(&proxies)
+
+	clientIDs := make([]uint, 0, len(proxies))
+	for _, p := range proxies {
+		clientIDs = append(clientIDs, p.FrpcConfigID)
+	}
+	clientMap := make(map[uint]models.FrpcConfig)
+	if len(clientIDs) > 0 {
+		var clients []models.FrpcConfig
+		db.Where("id IN ?", clientIDs).Find(&clients)
+		for _, client := range clients {
+			clientMap[client.ID] = client
+		}
+	}
+
+	// frps 中代理名称带有客户端 user 前缀
+	result := gin.H{"group": group}
+	liveMap := make(map[string]gin.H)
+	resp, err := getDashboardClient().GetProxies(group.Type)
+	if err != nil {
+		result["dashboard_error"] = err.Error()
+	} else {
+		for _, info := range resp.Proxies {
+			liveMap[info.Name] = gin.H{
+				"status":            info.Status,
+				"cur_conns":         info.CurConns,
+				"today_traffic_in":  info.TodayTrafficIn,
+				"today_traffic_out": info.TodayTrafficOut,
+				"last_start_time":   info.LastStartTime,
+				"last_close_time":   info.LastCloseTime,
+				"client_version":    info.ClientVersion,
+			}
+		}
+	}
+
+	type Member struct {
+		ProxyID    uint        `json:"proxy_id"`
+		ProxyName  string      `json:"proxy_name"`
+		ClientID   uint        `json:"client_id"`
+		ClientName string      `json:"client_name"`
+		ClientUser string      `json:"client_user"`
+		LocalIP    string      `json:"local_ip"`
+		LocalPort  int         `json:"local_port"`
+		Status     string      `json:"status"`
+		Live       interface{} `json:"live"`
+	}
+	members := make([]Member, 0, len(proxies))
+	online := 0
+	for _, p := range proxies {
+		client := clientMap[p.FrpcConfigID]
+		member := Member{
+			ProxyID:    p.ID,
+			ProxyName:  p.Name,
+			ClientID:   client.ID,
+			ClientName: client.Name,
+			ClientUser: client.User,
+			LocalIP:    p.LocalIP,
+			LocalPort:  p.LocalPort,
+			Status:     "unknown",
+		}
+		if err == nil {
+			member.Status = "offline"
+			if live, ok := liveMap[frpProxyName(&client, p.Name)]; ok {
+				member.Status = live["status"].(string)
+				member.Live = live
+				if member.Status == "online" {
+					online++
+				}
+			}
+		}
+		members = append(members, member)
+	}
+
+	result["members"] = members
+	result["online"] = online
+	c.JSON(http.StatusOK, result)
+}
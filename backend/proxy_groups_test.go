@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"frp-admin/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupProxyGroupTestDB 使用内存数据库替换全局 db
+func setupProxyGroupTestDB(t *testing.T) {
+	t.Helper()
+	testDB, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.AutoMigrate(&models.Proxy{}, &models.ProxyGroup{}); err != nil {
+		t.Fatal(err)
+	}
+	prev := db
+	db = testDB
+	t.Cleanup(func() { db = prev })
+}
+
+func serveProxyGroupRequest(r *gin.Engine, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestProxyGroupRecreateAfterDelete(t *testing.T) {
+	setupProxyGroupTestDB(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/proxy-groups", createProxyGroupHandler)
+	r.DELETE("/proxy-groups/:id", deleteProxyGroupHandler)
+
+	group := map[string]interface{}{"name": "web", "type": "http", "group_key": "k"}
+	w := serveProxyGroupRequest(r, http.MethodPost, "/proxy-groups", group)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: %d %s", w.Code, w.Body)
+	}
+	var created models.ProxyGroup
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	w = serveProxyGroupRequest(r, http.MethodPost, "/proxy-groups", group)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("duplicate name should be rejected, got %d %s", w.Code, w.Body)
+	}
+
+	w = serveProxyGroupRequest(r, http.MethodDelete, fmt.Sprintf("/proxy-groups/%d", created.ID), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", w.Code, w.Body)
+	}
+
+	w = serveProxyGroupRequest(r, http.MethodPost, "/proxy-groups", group)
+	if w.Code != http.StatusOK {
+		t.Fatalf("recreate after delete: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AccessWindow 每周的访问时间段，Days 为星期（0 表示周日），Start/End 为 HH:MM
+// End 小于 Start 时表示跨越午夜，如 22:00-06:00。时间段本身不带时区，按传入时间所在的时区判断
+type AccessWindow struct {
+	Days  []int  `json:"days"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// ParseAccessWindows 解析并校验访问时间段 JSON，空字符串表示不限制
+func ParseAccessWindows(raw string) ([]AccessWindow, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var windows []AccessWindow
+	if err := json.Unmarshal([]byte(raw), &windows); err != nil {
+		return nil, fmt.Errorf("访问时间段格式错误，应为 JSON 数组: %v", err)
+	}
+	for i, w := range windows {
+		if len(w.Days) == 0 {
+			return nil, fmt.Errorf("第 %d 个访问时间段未设置星期", i+1)
+		}
+		for _, d := range w.Days {
+			if d < 0 || d > 6 {
+				return nil, fmt.Errorf("第 %d 个访问时间段的星期应为 0-6", i+1)
+			}
+		}
+		start, err1 := parseClock(w.Start)
+		end, err2 := parseClock(w.End)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("第 %d 个访问时间段的时间应为 HH:MM", i+1)
+		}
+		if start == end {
+			return nil, fmt.Errorf("第 %d 个访问时间段的开始和结束时间不能相同", i+1)
+		}
+	}
+	return windows, nil
+}
+
+// parseClock 将 HH:MM 转换为当天的分钟数，24:00 表示当天结束
+func parseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil {
+		return 0, err
+	}
+	if h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %s", s)
+	}
+	return h*60 + m, nil
+}
+
+// InAccessWindows 判断 now 是否落在任一访问时间段内，没有时间段时始终返回 true
+// 星期和时刻取自 now 所在的时区，调用方需先用 In 转换到时间段所用的时区
+func InAccessWindows(windows []AccessWindow, now time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	minute := now.Hour()*60 + now.Minute()
+	today := int(now.Weekday())
+	yesterday := (today + 6) % 7
+
+	for _, w := range windows {
+		start, _ := parseClock(w.Start)
+		end, _ := parseClock(w.End)
+		if start < end {
+			if containsDay(w.Days, today) && minute >= start && minute < end {
+				return true
+			}
+			continue
+		}
+		// 跨越午夜：开始当天的 start 之后，或次日的 end 之前
+		if containsDay(w.Days, today) && minute >= start {
+			return true
+		}
+		if containsDay(w.Days, yesterday) && minute < end {
+			return true
+		}
+	}
+	return false
+}
+
+func containsDay(days []int, day int) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckAccess 根据禁用标记、过期时间和访问时间段判断是否允许访问，不允许时返回原因
+func CheckAccess(disabled bool, expiresAt *time.Time, windowsRaw string, now time.Time) string {
+	if disabled {
+		return "disabled"
+	}
+	if expiresAt != nil && !now.Before(*expiresAt) {
+		return "expired at " + expiresAt.Format(time.RFC3339)
+	}
+	windows, err := ParseAccessWindows(windowsRaw)
+	if err != nil {
+		return "invalid access windows"
+	}
+	if !InAccessWindows(windows, now) {
+		return "outside access window"
+	}
+	return ""
+}
@@ -0,0 +1,548 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============= frps/frpc 多版本管理 =============
+
+const (
+	// 版本目录中记录来源和校验和的文件
+	frpManifestFile = "manifest.json"
+	// 指向当前使用版本目录的符号链接
+	frpCurrentLink = "current"
+	// 执行 --version 的超时时间
+	frpVersionTimeout = 10 * time.Second
+	// 解压出的单个可执行文件大小上限，防止压缩炸弹写满磁盘（frps/frpc 通常在 30MB 以内）
+	maxFrpBinarySize = 256 << 20
+)
+
+// FrpBinaryNames 版本目录中管理的可执行文件
+var FrpBinaryNames = []string{"frps", "frpc"}
+
+var (
+	frpVersionRe = regexp.MustCompile(`^v?(\d+\.\d+\.\d+)$`)
+	// 官方 release 压缩包命名：frp_0.61.0_linux_amd64.tar.gz
+	frpReleaseNameRe = regexp.MustCompile(`^frp_v?(\d+\.\d+\.\d+)_([a-z0-9]+)_([a-z0-9]+)\.(?:tar\.gz|tgz|zip)$`)
+	// 压缩包中会被解压的文件
+	frpArchiveNames = []string{"frps", "frpc", "frps.exe", "frpc.exe"}
+)
+
+// FrpVersion 一个已导入的 frp 版本
+type FrpVersion struct {
+	Version       string            `json:"version"`
+	Source        string            `json:"source"`         // 导入的压缩包文件名
+	ArchiveSHA256 string            `json:"archive_sha256"` // 压缩包的 sha256
+	ImportedAt    time.Time         `json:"imported_at"`
+	Checksums     map[string]string `json:"checksums"` // 相对版本目录的文件路径 -> sha256
+	Platforms     []string          `json:"platforms"` // 可提供 frpc 的平台，如 linux_amd64
+	Active        bool              `json:"active"`
+	ChecksumError string            `json:"checksum_error,omitempty"` // 文件缺失或校验和不一致
+}
+
+// FrpBinaryStore 在本地目录中保存多个版本，目录结构为 <dir>/<version>/{frps,frpc,manifest.json}
+// 其他平台的 frpc 保存在 <dir>/<version>/clients/<os>_<arch>/ 中，用于生成客户端安装包
+// 当前版本通过 <dir>/current 符号链接切换，frps 管理器使用链接中的路径，切换后重启即可生效
+type FrpBinaryStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func NewFrpBinaryStore(dir string) *FrpBinaryStore {
+	return &FrpBinaryStore{dir: dir}
+}
+
+// ActiveVersion 返回当前版本，没有激活任何版本时为空
+func (s *FrpBinaryStore) ActiveVersion() string {
+	target, err := os.Readlink(filepath.Join(s.dir, frpCurrentLink))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// ActivePath 返回当前版本中可执行文件的路径（经过 current 链接），不存在时为空
+func (s *FrpBinaryStore) ActivePath(name string) string {
+	if s.ActiveVersion() == "" {
+		return ""
+	}
+	path := filepath.Join(s.dir, frpCurrentLink, name)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// VersionPath 返回指定版本中可执行文件的路径
+func (s *FrpBinaryStore) VersionPath(version, name string) string {
+	return filepath.Join(s.dir, version, name)
+}
+
+// List 返回所有版本并校验文件，按版本号从新到旧排序
+func (s *FrpBinaryStore) List() ([]FrpVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []FrpVersion{}, nil
+		}
+		return nil, err
+	}
+	active := s.ActiveVersion()
+	versions := []FrpVersion{}
+	for _, e := range entries {
+		if !e.IsDir() || !frpVersionRe.MatchString(e.Name()) {
+			continue
+		}
+		v, err := s.readManifest(e.Name())
+		if err != nil {
+			continue
+		}
+		v.Active = v.Version == active
+		v.Platforms = frpPlatforms(v)
+		if err := s.verifyLocked(v); err != nil {
+			v.ChecksumError = err.Error()
+		}
+		versions = append(versions, *v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareFrpVersions(versions[i].Version, versions[j].Version) > 0
+	})
+	return versions, nil
+}
+
+func (s *FrpBinaryStore) readManifest(version string) (*FrpVersion, error) {
+	if !frpVersionRe.MatchString(version) {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, version, frpManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var v FrpVersion
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("invalid manifest for %s: %v", version, err)
+	}
+	v.Version = version
+	return &v, nil
+}
+
+// Verify 重新计算版本中可执行文件的 sha256 并与导入时记录的比较
+func (s *FrpBinaryStore) Verify(version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.readManifest(version)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("version %s not found", version)
+		}
+		return err
+	}
+	return s.verifyLocked(v)
+}
+
+func (s *FrpBinaryStore) verifyLocked(v *FrpVersion) error {
+	for name, expected := range v.Checksums {
+		actual, err := fileSHA256(s.VersionPath(v.Version, name))
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if actual != expected {
+			return fmt.Errorf("%s checksum mismatch: expected %s, got %s", name, expected, actual)
+		}
+	}
+	return nil
+}
+
+// Activate 校验后将 current 链接切换到指定版本，返回之前的版本
+func (s *FrpBinaryStore) Activate(version string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, err := s.readManifest(version)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("version %s not found", version)
+		}
+		return "", err
+	}
+	if _, ok := v.Checksums["frps"]; !ok {
+		return "", fmt.Errorf("version %s has no frps for this host", version)
+	}
+	if err := s.verifyLocked(v); err != nil {
+		return "", err
+	}
+	previous := s.ActiveVersion()
+	// 先创建临时链接再 rename，切换过程中 current 始终有效
+	tmp := filepath.Join(s.dir, frpCurrentLink+".tmp")
+	os.Remove(tmp)
+	if err := os.Symlink(version, tmp); err != nil {
+		return "", fmt.Errorf("create link failed: %v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, frpCurrentLink)); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("switch link failed: %v", err)
+	}
+	return previous, nil
+}
+
+// Deactivate 删除 current 链接，用于首次激活失败时恢复到未托管的状态
+func (s *FrpBinaryStore) Deactivate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(filepath.Join(s.dir, frpCurrentLink))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Delete 删除一个未在使用的版本
+func (s *FrpBinaryStore) Delete(version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !frpVersionRe.MatchString(version) {
+		return fmt.Errorf("invalid version: %s", version)
+	}
+	if version == s.ActiveVersion() {
+		return fmt.Errorf("version %s is active", version)
+	}
+	dir := filepath.Join(s.dir, version)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("version %s not found", version)
+	}
+	return os.RemoveAll(dir)
+}
+
+// Import 从 frp release 压缩包（.tar.gz 或 .zip）导入
+// 当前平台的压缩包导入 frps/frpc，版本号以 --version 的输出为准；
+// 其他平台的压缩包只导入 frpc 供客户端下载，平台和版本号从官方命名的文件名中获取
+// expectedSHA256 不为空时先校验压缩包
+func (s *FrpBinaryStore) Import(filename string, r io.Reader, expectedSHA256 string) (*FrpVersion, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("create binary dir failed: %v", err)
+	}
+	archive, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(archive, hash), r)
+	if err != nil {
+		return nil, fmt.Errorf("read archive failed: %v", err)
+	}
+	archiveSum := hex.EncodeToString(hash.Sum(nil))
+	if expectedSHA256 != "" && !strings.EqualFold(strings.TrimSpace(expectedSHA256), archiveSum) {
+		return nil, fmt.Errorf("archive checksum mismatch: expected %s, got %s", expectedSHA256, archiveSum)
+	}
+
+	staging, err := os.MkdirTemp(s.dir, ".import-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	if err := extractFrpBinaries(archive, size, filename, staging); err != nil {
+		return nil, err
+	}
+
+	version, platform := "", FrpPlatform(runtime.GOOS, runtime.GOARCH)
+	if m := frpReleaseNameRe.FindStringSubmatch(filepath.Base(filename)); m != nil {
+		version, platform = m[1], m[2]+"_"+m[3]
+	}
+	// 相对版本目录的目标路径 -> 解压后的文件
+	files := map[string]string{}
+	if platform != FrpPlatform(runtime.GOOS, runtime.GOARCH) {
+		goos, goarch, _ := strings.Cut(platform, "_")
+		rel := frpClientBinaryPath(goos, goarch)
+		path := filepath.Join(staging, filepath.Base(rel))
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("no %s found in archive", filepath.Base(rel))
+		}
+		files[rel] = path
+	} else {
+		version = ""
+		for _, name := range FrpBinaryNames {
+			path := filepath.Join(staging, name)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			// 能执行 --version 同时说明二进制与当前平台匹配
+			reported, err := FrpBinaryVersion(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s cannot run on this host: %v", name, err)
+			}
+			if version == "" {
+				version = reported
+			} else if version != reported {
+				return nil, fmt.Errorf("frps and frpc versions differ: %s, %s", version, reported)
+			}
+			files[name] = path
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no frps or frpc found in archive")
+		}
+	}
+
+	checksums := map[string]string{}
+	for rel, path := range files {
+		if checksums[rel], err = fileSHA256(path); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 同一版本的多个平台合并到一个版本目录
+	target := filepath.Join(s.dir, version)
+	v, err := s.readManifest(version)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		v = &FrpVersion{Version: version, Checksums: map[string]string{}}
+	}
+	for rel := range files {
+		if _, ok := v.Checksums[rel]; ok {
+			return nil, fmt.Errorf("version %s already contains %s", version, rel)
+		}
+	}
+	if v.Source == "" || files["frps"] != "" || files["frpc"] != "" {
+		v.Source, v.ArchiveSHA256, v.ImportedAt = filepath.Base(filename), archiveSum, time.Now()
+	}
+	for rel, path := range files {
+		dest := filepath.Join(target, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(path, dest); err != nil {
+			return nil, fmt.Errorf("save version failed: %v", err)
+		}
+		v.Checksums[rel] = checksums[rel]
+	}
+	data, _ := json.MarshalIndent(v, "", "  ")
+	if err := os.WriteFile(filepath.Join(target, frpManifestFile), data, 0644); err != nil {
+		return nil, err
+	}
+	v.Platforms = frpPlatforms(v)
+	return v, nil
+}
+
+// FrpPlatform 平台名称，与 release 压缩包命名一致
+func FrpPlatform(goos, goarch string) string {
+	return goos + "_" + goarch
+}
+
+// frpClientBinaryPath 其他平台 frpc 在版本目录中的相对路径
+func frpClientBinaryPath(goos, goarch string) string {
+	name := "frpc"
+	if goos == "windows" {
+		name = "frpc.exe"
+	}
+	return filepath.Join("clients", FrpPlatform(goos, goarch), name)
+}
+
+// frpPlatforms 根据版本中的文件列出可提供 frpc 的平台
+func frpPlatforms(v *FrpVersion) []string {
+	platforms := []string{}
+	if _, ok := v.Checksums["frpc"]; ok {
+		platforms = append(platforms, FrpPlatform(runtime.GOOS, runtime.GOARCH))
+	}
+	for rel := range v.Checksums {
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) == 3 && parts[0] == "clients" {
+			platforms = append(platforms, parts[1])
+		}
+	}
+	sort.Strings(platforms)
+	return platforms
+}
+
+// ClientBinary 返回指定版本中对应平台 frpc 的路径和文件名，返回前校验 sha256
+func (s *FrpBinaryStore) ClientBinary(version, goos, goarch string) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, err := s.readManifest(version)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", "", fmt.Errorf("version %s not found", version)
+		}
+		return "", "", err
+	}
+	rel := frpClientBinaryPath(goos, goarch)
+	if FrpPlatform(goos, goarch) == FrpPlatform(runtime.GOOS, runtime.GOARCH) {
+		rel = "frpc"
+	}
+	expected, ok := v.Checksums[rel]
+	if !ok {
+		return "", "", fmt.Errorf("frpc %s for %s is not imported", version, FrpPlatform(goos, goarch))
+	}
+	path := s.VersionPath(version, rel)
+	actual, err := fileSHA256(path)
+	if err != nil {
+		return "", "", err
+	}
+	if actual != expected {
+		return "", "", fmt.Errorf("%s checksum mismatch: expected %s, got %s", rel, expected, actual)
+	}
+	return path, filepath.Base(rel), nil
+}
+
+// extractFrpBinaries 只解压压缩包中的 frps/frpc 可执行文件，忽略目录结构
+func extractFrpBinaries(archive *os.File, size int64, filename, dest string) error {
+	header := make([]byte, 4)
+	if _, err := archive.ReadAt(header, 0); err != nil {
+		return fmt.Errorf("read archive failed: %v", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(archive, size)
+		if err != nil {
+			return fmt.Errorf("invalid zip archive: %v", err)
+		}
+		for _, f := range zr.File {
+			name := frpBinaryName(f.Name)
+			if name == "" || !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = writeFrpBinary(filepath.Join(dest, name), rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		if _, err := archive.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		gz, err := gzip.NewReader(archive)
+		if err != nil {
+			return fmt.Errorf("invalid gzip archive: %v", err)
+		}
+		defer gz.Close()
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("invalid tar archive: %v", err)
+			}
+			name := frpBinaryName(hdr.Name)
+			if name == "" || hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := writeFrpBinary(filepath.Join(dest, name), tr); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported archive %s, expected .tar.gz or .zip", filepath.Base(filename))
+	}
+	return nil
+}
+
+func frpBinaryName(path string) string {
+	base := filepath.Base(filepath.ToSlash(path))
+	for _, name := range frpArchiveNames {
+		if base == name {
+			return name
+		}
+	}
+	return ""
+}
+
+func writeFrpBinary(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, maxFrpBinarySize+1))
+	if err == nil && n > maxFrpBinarySize {
+		err = fmt.Errorf("%s exceeds the %d MB size limit", filepath.Base(path), maxFrpBinarySize>>20)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// FrpBinaryVersion 执行 <path> --version 获取版本号
+func FrpBinaryVersion(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), frpVersionTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	version := strings.TrimSpace(string(output))
+	m := frpVersionRe.FindStringSubmatch(version)
+	if m == nil {
+		return "", fmt.Errorf("unexpected version output: %s", version)
+	}
+	return m[1], nil
+}
+
+// compareFrpVersions 按数字比较 x.y.z 版本号
+func compareFrpVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		var na, nb int
+		fmt.Sscanf(pa[i], "%d", &na)
+		fmt.Sscanf(pb[i], "%d", &nb)
+		if na != nb {
+			if na > nb {
+				return 1
+			}
+			return -1
+		}
+	}
+	return len(pa) - len(pb)
+}
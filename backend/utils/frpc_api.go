@@ -30,13 +30,23 @@ type FrpcProxyStatus struct {
 
 // FrpcStatusResponse frpc status 响应
 type FrpcStatusResponse struct {
-	TCP   []FrpcProxyStatus `json:"tcp"`
-	UDP   []FrpcProxyStatus `json:"udp"`
-	HTTP  []FrpcProxyStatus `json:"http"`
-	HTTPS []FrpcProxyStatus `json:"https"`
-	STCP  []FrpcProxyStatus `json:"stcp"`
-	XTCP  []FrpcProxyStatus `json:"xtcp"`
-	SUDP  []FrpcProxyStatus `json:"sudp"`
+	TCP    []FrpcProxyStatus `json:"tcp"`
+	UDP    []FrpcProxyStatus `json:"udp"`
+	HTTP   []FrpcProxyStatus `json:"http"`
+	HTTPS  []FrpcProxyStatus `json:"https"`
+	TCPMux []FrpcProxyStatus `json:"tcpmux"`
+	STCP   []FrpcProxyStatus `json:"stcp"`
+	XTCP   []FrpcProxyStatus `json:"xtcp"`
+	SUDP   []FrpcProxyStatus `json:"sudp"`
+}
+
+// All 返回所有类型的代理状态
+func (s *FrpcStatusResponse) All() []FrpcProxyStatus {
+	var all []FrpcProxyStatus
+	for _, list := range [][]FrpcProxyStatus{s.TCP, s.UDP, s.HTTP, s.HTTPS, s.TCPMux, s.STCP, s.XTCP, s.SUDP} {
+		all = append(all, list...)
+	}
+	return all
 }
 
 // NewFrpcClient 创建 frpc API 客户端
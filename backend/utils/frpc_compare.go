@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// FrpcConfigDiff 两份 frpc 配置之间的差异（from -> to）
+type FrpcConfigDiff struct {
+	InSync   bool        `json:"in_sync"`
+	Common   []FieldDiff `json:"common"`   // 全局配置项差异
+	Proxies  SectionDiff `json:"proxies"`  // [[proxies]] 差异
+	Visitors SectionDiff `json:"visitors"` // [[visitors]] 差异
+}
+
+// SectionDiff 按 name 对比的代理或访问者差异
+type SectionDiff struct {
+	Added   []string   `json:"added"`   // 仅存在于 to
+	Removed []string   `json:"removed"` // 仅存在于 from
+	Changed []ItemDiff `json:"changed"`
+}
+
+type ItemDiff struct {
+	Name   string      `json:"name"`
+	Fields []FieldDiff `json:"fields"`
+}
+
+// FieldDiff 单个配置项的差异，值为 nil 表示未设置
+type FieldDiff struct {
+	Key  string      `json:"key"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// CompareFrpcConfigs 解析并逐项比较两份 frpc TOML 配置
+func CompareFrpcConfigs(from, to string) (*FrpcConfigDiff, error) {
+	fromDoc, err := decodeFrpcToml(from)
+	if err != nil {
+		return nil, fmt.Errorf("parse from config failed: %v", err)
+	}
+	toDoc, err := decodeFrpcToml(to)
+	if err != nil {
+		return nil, fmt.Errorf("parse to config failed: %v", err)
+	}
+
+	fromProxies, fromVisitors := splitFrpcSections(fromDoc)
+	toProxies, toVisitors := splitFrpcSections(toDoc)
+
+	diff := &FrpcConfigDiff{
+		Common:   diffFlatMaps(flattenToml("", fromDoc), flattenToml("", toDoc)),
+		Proxies:  diffSections(fromProxies, toProxies),
+		Visitors: diffSections(fromVisitors, toVisitors),
+	}
+	diff.InSync = len(diff.Common) == 0 && diff.Proxies.empty() && diff.Visitors.empty()
+	return diff, nil
+}
+
+func (s SectionDiff) empty() bool {
+	return len(s.Added) == 0 && len(s.Removed) == 0 && len(s.Changed) == 0
+}
+
+func decodeFrpcToml(content string) (map[string]interface{}, error) {
+	doc := map[string]interface{}{}
+	if err := toml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// splitFrpcSections 从文档中取出 proxies 和 visitors，按 name 建立索引
+func splitFrpcSections(doc map[string]interface{}) (map[string]map[string]interface{}, map[string]map[string]interface{}) {
+	index := func(key string) map[string]map[string]interface{} {
+		result := map[string]map[string]interface{}{}
+		items, _ := doc[key].([]interface{})
+		for i, item := range items {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := m["name"].(string)
+			if name == "" {
+				name = fmt.Sprintf("#%d", i)
+			}
+			result[name] = m
+		}
+		delete(doc, key)
+		return result
+	}
+	return index("proxies"), index("visitors")
+}
+
+func diffSections(from, to map[string]map[string]interface{}) SectionDiff {
+	diff := SectionDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []ItemDiff{},
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+	for name, fromItem := range from {
+		toItem, ok := to[name]
+		if !ok {
+			diff.Removed = append(diff.Removed, name)
+			continue
+		}
+		if fields := diffFlatMaps(flattenToml("", fromItem), flattenToml("", toItem)); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, ItemDiff{Name: name, Fields: fields})
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Name < diff.Changed[j].Name })
+	return diff
+}
+
+// flattenToml 将嵌套表展开为点分键，如 {"transport": {"useEncryption": true}} -> transport.useEncryption
+func flattenToml(prefix string, m map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if sub, ok := v.(map[string]interface{}); ok {
+			for sk, sv := range flattenToml(key, sub) {
+				result[sk] = sv
+			}
+			continue
+		}
+		result[key] = v
+	}
+	return result
+}
+
+func diffFlatMaps(from, to map[string]interface{}) []FieldDiff {
+	keys := map[string]bool{}
+	for k := range from {
+		keys[k] = true
+	}
+	for k := range to {
+		keys[k] = true
+	}
+
+	diffs := []FieldDiff{}
+	for k := range keys {
+		if !reflect.DeepEqual(from[k], to[k]) {
+			diffs = append(diffs, FieldDiff{Key: k, From: from[k], To: to[k]})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+	return diffs
+}
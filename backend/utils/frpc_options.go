@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// FrpcClientOptions frpc 客户端级别的传输、TLS、心跳和日志选项
+// 字符串为空、整数为 0、布尔指针为 nil 表示未设置，生成时沿用上一级默认值
+type FrpcClientOptions struct {
+	TransportProtocol string `json:"transport_protocol"` // tcp, kcp, quic, websocket, wss
+	DialServerTimeout int    `json:"dial_server_timeout"`
+	ProxyURL          string `json:"proxy_url"` // 通过 HTTP/SOCKS5 代理连接 frps
+	PoolCount         int    `json:"pool_count"`
+	TCPMux            *bool  `json:"tcp_mux"`
+	HeartbeatInterval int    `json:"heartbeat_interval"` // -1 表示关闭心跳
+	HeartbeatTimeout  int    `json:"heartbeat_timeout"`
+
+	TLSEnable                    *bool  `json:"tls_enable"`
+	TLSCertFile                  string `json:"tls_cert_file"`
+	TLSKeyFile                   string `json:"tls_key_file"`
+	TLSTrustedCaFile             string `json:"tls_trusted_ca_file"`
+	TLSServerName                string `json:"tls_server_name"`
+	TLSDisableCustomTLSFirstByte *bool  `json:"tls_disable_custom_tls_first_byte"`
+
+	LoginFailExit *bool  `json:"login_fail_exit"`
+	LogTo         string `json:"log_to"`
+	LogLevel      string `json:"log_level"`
+	LogMaxDays    int    `json:"log_max_days"`
+}
+
+// 未设置任何默认值时沿用的日志和登录配置
+var builtinFrpcClientOptions = FrpcClientOptions{
+	LoginFailExit: boolPtr(false),
+	LogTo:         "/var/log/frpc.log",
+	LogLevel:      "info",
+	LogMaxDays:    3,
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// MergeFrpcClientOptions 逐项合并选项，靠前的参数优先
+func MergeFrpcClientOptions(layers ...FrpcClientOptions) FrpcClientOptions {
+	var result FrpcClientOptions
+	for i := len(layers) - 1; i >= 0; i-- {
+		o := layers[i]
+		if o.TransportProtocol != "" {
+			result.TransportProtocol = o.TransportProtocol
+		}
+		if o.DialServerTimeout != 0 {
+			result.DialServerTimeout = o.DialServerTimeout
+		}
+		if o.ProxyURL != "" {
+			result.ProxyURL = o.ProxyURL
+		}
+		if o.PoolCount != 0 {
+			result.PoolCount = o.PoolCount
+		}
+		if o.TCPMux != nil {
+			result.TCPMux = o.TCPMux
+		}
+		if o.HeartbeatInterval != 0 {
+			result.HeartbeatInterval = o.HeartbeatInterval
+		}
+		if o.HeartbeatTimeout != 0 {
+			result.HeartbeatTimeout = o.HeartbeatTimeout
+		}
+		if o.TLSEnable != nil {
+			result.TLSEnable = o.TLSEnable
+		}
+		if o.TLSCertFile != "" {
+			result.TLSCertFile = o.TLSCertFile
+		}
+		if o.TLSKeyFile != "" {
+			result.TLSKeyFile = o.TLSKeyFile
+		}
+		if o.TLSTrustedCaFile != "" {
+			result.TLSTrustedCaFile = o.TLSTrustedCaFile
+		}
+		if o.TLSServerName != "" {
+			result.TLSServerName = o.TLSServerName
+		}
+		if o.TLSDisableCustomTLSFirstByte != nil {
+			result.TLSDisableCustomTLSFirstByte = o.TLSDisableCustomTLSFirstByte
+		}
+		if o.LoginFailExit != nil {
+			result.LoginFailExit = o.LoginFailExit
+		}
+		if o.LogTo != "" {
+			result.LogTo = o.LogTo
+		}
+		if o.LogLevel != "" {
+			result.LogLevel = o.LogLevel
+		}
+		if o.LogMaxDays != 0 {
+			result.LogMaxDays = o.LogMaxDays
+		}
+	}
+	return result
+}
+
+// ValidateFrpcClientOptions 校验选项取值是否为 frpc 支持的值
+func ValidateFrpcClientOptions(o FrpcClientOptions) error {
+	switch o.TransportProtocol {
+	case "", "tcp", "kcp", "quic", "websocket", "wss":
+	default:
+		return fmt.Errorf("不支持的传输协议: %s", o.TransportProtocol)
+	}
+	switch o.LogLevel {
+	case "", "trace", "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("不支持的日志级别: %s", o.LogLevel)
+	}
+	if o.DialServerTimeout < 0 || o.PoolCount < 0 || o.HeartbeatTimeout < 0 || o.LogMaxDays < 0 {
+		return fmt.Errorf("连接超时、连接池、心跳超时和日志保留天数不能为负数")
+	}
+	if o.HeartbeatInterval < -1 {
+		return fmt.Errorf("心跳间隔应大于 0，或为 -1 表示关闭心跳")
+	}
+	if o.HeartbeatInterval > 0 && o.HeartbeatTimeout > 0 && o.HeartbeatTimeout <= o.HeartbeatInterval {
+		return fmt.Errorf("心跳超时应大于心跳间隔")
+	}
+	if o.ProxyURL != "" {
+		u, err := url.Parse(o.ProxyURL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("代理地址格式错误: %s", o.ProxyURL)
+		}
+		switch u.Scheme {
+		case "http", "socks5", "ntlm":
+		default:
+			return fmt.Errorf("代理地址仅支持 http、socks5、ntlm 协议")
+		}
+	}
+	if (o.TLSCertFile == "") != (o.TLSKeyFile == "") {
+		return fmt.Errorf("TLS 证书和私钥需要同时设置")
+	}
+	return nil
+}
+
+// writeFrpcTransportOptions 输出 transport 配置，未设置的项不输出，由 frpc 使用默认值
+func writeFrpcTransportOptions(w *tomlWriter, o FrpcClientOptions) {
+	transport := []struct {
+		key   string
+		value interface{}
+		set   bool
+	}{
+		{"transport.protocol", o.TransportProtocol, o.TransportProtocol != ""},
+		{"transport.dialServerTimeout", o.DialServerTimeout, o.DialServerTimeout > 0},
+		{"transport.proxyURL", o.ProxyURL, o.ProxyURL != ""},
+		{"transport.poolCount", o.PoolCount, o.PoolCount > 0},
+		{"transport.tcpMux", o.TCPMux, o.TCPMux != nil},
+		{"transport.heartbeatInterval", o.HeartbeatInterval, o.HeartbeatInterval != 0},
+		{"transport.heartbeatTimeout", o.HeartbeatTimeout, o.HeartbeatTimeout > 0},
+		{"transport.tls.enable", o.TLSEnable, o.TLSEnable != nil},
+		{"transport.tls.certFile", o.TLSCertFile, o.TLSCertFile != ""},
+		{"transport.tls.keyFile", o.TLSKeyFile, o.TLSKeyFile != ""},
+		{"transport.tls.trustedCaFile", o.TLSTrustedCaFile, o.TLSTrustedCaFile != ""},
+		{"transport.tls.serverName", o.TLSServerName, o.TLSServerName != ""},
+		{"transport.tls.disableCustomTLSFirstByte", o.TLSDisableCustomTLSFirstByte, o.TLSDisableCustomTLSFirstByte != nil},
+	}
+	wrote := false
+	for _, item := range transport {
+		if !item.set {
+			continue
+		}
+		value := item.value
+		if b, ok := value.(*bool); ok {
+			value = *b
+		}
+		w.KV(item.key, value)
+		wrote = true
+	}
+	if wrote {
+		w.Blank()
+	}
+}
@@ -0,0 +1,439 @@
+package utils
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============= Docker 容器管理模式 =============
+
+const (
+	// 默认的 Docker Engine unix socket
+	DefaultDockerSocket = "/var/run/docker.sock"
+	// 普通 API 请求的超时时间，停止容器时额外加上宽限期
+	dockerAPITimeout = 30 * time.Second
+	// 验证配置时复制到临时容器中的配置文件路径
+	dockerVerifyConfigPath = "/frp-admin-verify.toml"
+)
+
+// DockerManager 通过 Docker Engine API 管理运行 frps 的容器
+type DockerManager struct {
+	socketPath  string
+	container   string // 容器名称或 ID
+	configPath  string
+	stopTimeout time.Duration
+	client      *http.Client
+}
+
+// dockerContainer /containers/{id}/json 响应中用到的字段
+type dockerContainer struct {
+	ID    string `json:"Id"`
+	Image string `json:"Image"`
+	Path  string `json:"Path"` // 容器中实际运行的可执行文件
+	State struct {
+		Running   bool   `json:"Running"`
+		Pid       int    `json:"Pid"`
+		ExitCode  int    `json:"ExitCode"`
+		StartedAt string `json:"StartedAt"`
+	} `json:"State"`
+	Config struct {
+		Tty bool `json:"Tty"`
+	} `json:"Config"`
+}
+
+// NewDockerManager socketPath 为空时使用 DefaultDockerSocket，可以指向测试用的 socket
+func NewDockerManager(socketPath, container, configPath string, stopTimeout time.Duration) *DockerManager {
+	socketPath = strings.TrimPrefix(socketPath, "unix://")
+	if socketPath == "" {
+		socketPath = DefaultDockerSocket
+	}
+	if stopTimeout <= 0 {
+		stopTimeout = DefaultFrpsStopTimeout
+	}
+	return &DockerManager{
+		socketPath:  socketPath,
+		container:   container,
+		configPath:  configPath,
+		stopTimeout: stopTimeout,
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+func (m *DockerManager) GetManagerType() string {
+	return "docker"
+}
+
+// SetFrpsPath 容器中的 frps 来自镜像，本地可执行文件不影响 docker 模式
+func (m *DockerManager) SetFrpsPath(path string) {}
+
+// do 调用 Docker API，非 2xx 状态码时返回 API 的错误信息；调用方负责关闭响应
+func (m *DockerManager) do(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader) (*http.Response, error) {
+	u := "http://docker" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker API request failed: %v", err)
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return nil, fmt.Errorf("docker API error (%d): %s", resp.StatusCode, apiErr.Message)
+		}
+		return nil, fmt.Errorf("docker API error (%d): %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return resp, nil
+}
+
+// call 执行不需要读取流的请求，result 不为 nil 时解析 JSON 响应
+func (m *DockerManager) call(method, path string, query url.Values, payload, result interface{}, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var body io.Reader
+	contentType := ""
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+	resp, err := m.do(ctx, method, path, query, contentType, body)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if result != nil && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return resp.StatusCode, fmt.Errorf("decode docker response failed: %v", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func (m *DockerManager) containerPath(action string) string {
+	path := "/containers/" + url.PathEscape(m.container)
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
+
+func (m *DockerManager) inspect() (*dockerContainer, error) {
+	var c dockerContainer
+	if _, err := m.call(http.MethodGet, m.containerPath("json"), nil, nil, &c, dockerAPITimeout); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (m *DockerManager) Start() error {
+	status, err := m.call(http.MethodPost, m.containerPath("start"), nil, nil, nil, dockerAPITimeout)
+	if err != nil {
+		return fmt.Errorf("failed to start container %s: %v", m.container, err)
+	}
+	if status == http.StatusNotModified {
+		return fmt.Errorf("frps is already running")
+	}
+	return nil
+}
+
+// Stop 由 Docker 发送 SIGTERM，超过宽限期后发送 SIGKILL，退出码 137 表示被强制结束
+func (m *DockerManager) Stop() (*FrpsLifecycleResult, error) {
+	begin := time.Now()
+	result := &FrpsLifecycleResult{Action: "stop"}
+	if err := m.stop(result); err != nil {
+		return nil, err
+	}
+	result.TotalMs = time.Since(begin).Milliseconds()
+	return result, nil
+}
+
+func (m *DockerManager) stop(result *FrpsLifecycleResult) error {
+	before, err := m.inspect()
+	if err != nil {
+		return err
+	}
+	if !before.State.Running {
+		return fmt.Errorf("frps is not running")
+	}
+	result.PID = before.State.Pid
+
+	begin := time.Now()
+	seconds := int(m.stopTimeout.Seconds())
+	query := url.Values{"t": {strconv.Itoa(seconds)}}
+	if _, err := m.call(http.MethodPost, m.containerPath("stop"), query, nil, nil, dockerAPITimeout+m.stopTimeout); err != nil {
+		return fmt.Errorf("failed to stop container %s: %v", m.container, err)
+	}
+	result.StopMs = time.Since(begin).Milliseconds()
+
+	result.Signal = "SIGTERM"
+	if after, err := m.inspect(); err == nil && after.State.ExitCode == 137 {
+		result.Signal = "SIGKILL"
+		result.Escalated = true
+	}
+	return nil
+}
+
+// Restart 分别停止和启动容器，端口由 Docker 在容器停止后释放
+func (m *DockerManager) Restart() (*FrpsLifecycleResult, error) {
+	begin := time.Now()
+	result := &FrpsLifecycleResult{Action: "restart"}
+	if m.Status().Running {
+		if err := m.stop(result); err != nil {
+			return nil, err
+		}
+	}
+
+	startBegin := time.Now()
+	if err := m.Start(); err != nil {
+		return nil, err
+	}
+	result.StartMs = time.Since(startBegin).Milliseconds()
+	result.NewPID = m.Status().PID
+	result.TotalMs = time.Since(begin).Milliseconds()
+	return result, nil
+}
+
+func (m *DockerManager) Status() FrpsStatus {
+	status := FrpsStatus{ManagerType: "docker"}
+	c, err := m.inspect()
+	if err != nil || !c.State.Running {
+		return status
+	}
+	status.Running = true
+	status.PID = c.State.Pid
+	if t, err := time.Parse(time.RFC3339Nano, c.State.StartedAt); err == nil {
+		status.StartTime = t
+		status.Uptime = time.Since(t).Round(time.Second).String()
+	}
+	return status
+}
+
+// Verify 使用 frps 容器的镜像和挂载卷创建一次性容器执行 frps verify，结束后删除
+func (m *DockerManager) Verify(configPath string) error {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("read config failed: %v", err)
+	}
+	frps, err := m.inspect()
+	if err != nil {
+		return err
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	spec := map[string]interface{}{
+		"Image":      frps.Image,
+		"Entrypoint": []string{frps.Path},
+		"Cmd":        []string{"verify", "-c", dockerVerifyConfigPath},
+		"HostConfig": map[string]interface{}{
+			// 复用 frps 容器的挂载卷，配置中引用的证书等文件路径保持一致
+			"VolumesFrom": []string{frps.ID + ":ro"},
+			"NetworkMode": "none",
+		},
+	}
+	if _, err := m.call(http.MethodPost, "/containers/create", nil, spec, &created, dockerAPITimeout); err != nil {
+		return fmt.Errorf("create verify container failed: %v", err)
+	}
+	verifyPath := "/containers/" + created.ID
+	defer m.call(http.MethodDelete, verifyPath, url.Values{"force": {"true"}}, nil, nil, dockerAPITimeout)
+
+	archive, err := tarSingleFile(strings.TrimPrefix(dockerVerifyConfigPath, "/"), content)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dockerAPITimeout)
+	defer cancel()
+	resp, err := m.do(ctx, http.MethodPut, verifyPath+"/archive", url.Values{"path": {"/"}}, "application/x-tar", bytes.NewReader(archive))
+	if err != nil {
+		return fmt.Errorf("copy config to verify container failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := m.call(http.MethodPost, verifyPath+"/start", nil, nil, nil, dockerAPITimeout); err != nil {
+		return fmt.Errorf("start verify container failed: %v", err)
+	}
+	var waited struct {
+		StatusCode int `json:"StatusCode"`
+	}
+	if _, err := m.call(http.MethodPost, verifyPath+"/wait", nil, nil, &waited, dockerAPITimeout); err != nil {
+		return fmt.Errorf("wait verify container failed: %v", err)
+	}
+	if waited.StatusCode != 0 {
+		output, _ := m.readLogs(verifyPath, false, url.Values{"stdout": {"1"}, "stderr": {"1"}})
+		return fmt.Errorf("config verification failed: %s", strings.Join(output, "\n"))
+	}
+	return nil
+}
+
+// tarSingleFile 生成只包含一个文件的 tar，用于 PUT /containers/{id}/archive
+func tarSingleFile(name string, content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: time.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(content); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readLogs 读取容器日志的全部行
+func (m *DockerManager) readLogs(containerPath string, tty bool, query url.Values) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dockerAPITimeout)
+	defer cancel()
+	resp, err := m.do(ctx, http.MethodGet, containerPath+"/logs", query, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	logs := dockerLogReader(resp.Body, tty)
+	defer logs.Close()
+	var lines []string
+	scanner := bufio.NewScanner(logs)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// dockerLogReader 未分配 TTY 的容器日志按 8 字节头的帧复用 stdout/stderr，解出其中的内容。
+// 调用方不再读取时必须 Close，否则解帧的 goroutine 会一直阻塞在写入上
+func dockerLogReader(r io.Reader, tty bool) io.ReadCloser {
+	if tty {
+		return io.NopCloser(r)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		header := make([]byte, 8)
+		for {
+			if _, err := io.ReadFull(r, header); err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				pw.CloseWithError(err)
+				return
+			}
+			size := int64(binary.BigEndian.Uint32(header[4:]))
+			if _, err := io.CopyN(pw, r, size); err != nil {
+				// 帧内容不完整
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+func (m *DockerManager) GetLogs(lines int) []string {
+	c, err := m.inspect()
+	if err != nil {
+		return []string{fmt.Sprintf("Failed to get logs: %v", err)}
+	}
+	tail := "all"
+	if lines > 0 {
+		tail = strconv.Itoa(lines)
+	}
+	logLines, err := m.readLogs(m.containerPath(""), c.Config.Tty, url.Values{"stdout": {"1"}, "stderr": {"1"}, "tail": {tail}})
+	if err != nil {
+		return []string{fmt.Sprintf("Failed to get logs: %v", err)}
+	}
+	return logLines
+}
+
+// QueryLogs 通过 logs 接口的 since/until 缩小范围，其余条件在解析后过滤
+func (m *DockerManager) QueryLogs(q FrpsLogQuery) (*FrpsLogPage, error) {
+	c, err := m.inspect()
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}, "tail": {strconv.Itoa(maxJournalLines)}}
+	if !q.Since.IsZero() {
+		query.Set("since", strconv.FormatInt(q.Since.Unix(), 10))
+	}
+	if !q.Until.IsZero() {
+		query.Set("until", strconv.FormatInt(q.Until.Unix()+1, 10))
+	}
+	lines, err := m.readLogs(m.containerPath(""), c.Config.Tty, query)
+	if err != nil {
+		return nil, err
+	}
+	return queryFrpsLogLines(lines, q), nil
+}
+
+// FollowLogs 通过 logs 接口的 follow 模式跟踪新日志
+func (m *DockerManager) FollowLogs(ctx context.Context) (<-chan string, error) {
+	c, err := m.inspect()
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}, "follow": {"1"}, "tail": {"0"}}
+	resp, err := m.do(ctx, http.MethodGet, m.containerPath("logs"), query, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan string, frpsLogSubscriberBuffer)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+		logs := dockerLogReader(resp.Body, c.Config.Tty)
+		defer logs.Close()
+		scanner := bufio.NewScanner(logs)
+		for scanner.Scan() {
+			select {
+			case ch <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,302 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDocker 模拟 Docker Engine API 中 DockerManager 用到的接口
+type fakeDocker struct {
+	mu         sync.Mutex
+	running    bool
+	exitCode   int
+	stopQuery  string
+	created    map[string]interface{}
+	archive    map[string]string // 复制到验证容器中的文件
+	verifyExit int
+	verifyLogs string
+	deleted    bool
+	frpsLogs   []string
+}
+
+// dockerFrame 按 Docker 未分配 TTY 时的日志格式封装一帧
+func dockerFrame(stream byte, data string) []byte {
+	frame := make([]byte, 8, 8+len(data))
+	frame[0] = stream
+	binary.BigEndian.PutUint32(frame[4:], uint32(len(data)))
+	return append(frame, data...)
+}
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	writeJSON := func(status int, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/containers/frps/json":
+		writeJSON(http.StatusOK, map[string]interface{}{
+			"Id":    "frps-id",
+			"Image": "snowdreamtech/frps:latest",
+			"Path":  "/usr/bin/frps",
+			"State": map[string]interface{}{
+				"Running":   f.running,
+				"Pid":       map[bool]int{true: 4242}[f.running],
+				"ExitCode":  f.exitCode,
+				"StartedAt": time.Now().Add(-time.Minute).Format(time.RFC3339Nano),
+			},
+			"Config": map[string]interface{}{"Tty": false},
+		})
+	case r.Method == http.MethodGet && r.URL.Path == "/containers/missing/json":
+		writeJSON(http.StatusNotFound, map[string]string{"message": "No such container: missing"})
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/frps/start":
+		if f.running {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		f.running = true
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/frps/stop":
+		f.stopQuery = r.URL.RawQuery
+		f.running = false
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Path == "/containers/frps/logs":
+		for _, line := range f.frpsLogs {
+			w.Write(dockerFrame(1, line+"\n"))
+		}
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/create":
+		json.NewDecoder(r.Body).Decode(&f.created)
+		writeJSON(http.StatusCreated, map[string]string{"Id": "verify-id"})
+	case r.Method == http.MethodPut && r.URL.Path == "/containers/verify-id/archive":
+		f.archive = map[string]string{}
+		tr := tar.NewReader(r.Body)
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				break
+			}
+			data, _ := io.ReadAll(tr)
+			f.archive[r.URL.Query().Get("path")+hdr.Name] = string(data)
+		}
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/verify-id/start":
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/verify-id/wait":
+		writeJSON(http.StatusOK, map[string]int{"StatusCode": f.verifyExit})
+	case r.Method == http.MethodGet && r.URL.Path == "/containers/verify-id/logs":
+		w.Write(dockerFrame(2, f.verifyLogs))
+	case r.Method == http.MethodDelete && r.URL.Path == "/containers/verify-id":
+		f.deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeJSON(http.StatusNotFound, map[string]string{"message": "unexpected " + r.Method + " " + r.URL.Path})
+	}
+}
+
+// startFakeDocker 在临时 unix socket 上启动模拟的 Docker API
+func startFakeDocker(t *testing.T) (*fakeDocker, string) {
+	t.Helper()
+	// unix socket 路径长度有限，不使用可能很长的 t.TempDir()
+	dir, err := os.MkdirTemp("", "fdock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix socket not available: %v", err)
+	}
+
+	fake := &fakeDocker{}
+	srv := httptest.NewUnstartedServer(fake)
+	srv.Listener.Close()
+	srv.Listener = listener
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return fake, socket
+}
+
+func TestDockerManagerLifecycle(t *testing.T) {
+	fake, socket := startFakeDocker(t)
+	m := NewDockerManager("unix://"+socket, "frps", "", 3*time.Second)
+
+	if status := m.Status(); status.Running {
+		t.Fatalf("expected stopped container, got %+v", status)
+	}
+	if err := m.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := m.Start(); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Fatalf("expected already running error, got %v", err)
+	}
+	status := m.Status()
+	if !status.Running || status.PID != 4242 || status.ManagerType != "docker" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	result, err := m.Stop()
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if fake.stopQuery != "t=3" {
+		t.Errorf("stop timeout query = %q, want t=3", fake.stopQuery)
+	}
+	if result.PID != 4242 || result.Signal != "SIGTERM" || result.Escalated {
+		t.Errorf("unexpected stop result %+v", result)
+	}
+	if _, err := m.Stop(); err == nil {
+		t.Error("expected error when stopping a stopped container")
+	}
+
+	// 退出码 137 表示宽限期后被 SIGKILL
+	fake.mu.Lock()
+	fake.running, fake.exitCode = true, 137
+	fake.mu.Unlock()
+	result, err = m.Restart()
+	if err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	if !result.Escalated || result.Signal != "SIGKILL" || result.NewPID != 4242 {
+		t.Errorf("unexpected restart result %+v", result)
+	}
+}
+
+func TestDockerManagerInspectError(t *testing.T) {
+	_, socket := startFakeDocker(t)
+	m := NewDockerManager(socket, "missing", "", 0)
+	if _, err := m.Stop(); err == nil || !strings.Contains(err.Error(), "No such container") {
+		t.Fatalf("expected API error message, got %v", err)
+	}
+	if m.Status().Running {
+		t.Fatal("missing container must not be reported as running")
+	}
+}
+
+func TestDockerManagerLogs(t *testing.T) {
+	fake, socket := startFakeDocker(t)
+	fake.frpsLogs = []string{
+		"2024/01/02 03:04:05 [I] [service.go:1] frps started",
+		"2024/01/02 03:04:06 [W] [control.go:2] [user1] proxy ssh closed",
+	}
+	m := NewDockerManager(socket, "frps", "", 0)
+
+	lines := m.GetLogs(10)
+	if len(lines) != 2 || lines[0] != fake.frpsLogs[0] || lines[1] != fake.frpsLogs[1] {
+		t.Fatalf("unexpected logs %q", lines)
+	}
+	page, err := m.QueryLogs(FrpsLogQuery{Level: "warn", Limit: 10})
+	if err != nil {
+		t.Fatalf("query logs: %v", err)
+	}
+	if page.Total != 1 || page.Entries[0].Level != "warn" {
+		t.Fatalf("unexpected query result %+v", page)
+	}
+}
+
+func TestDockerLogReaderFraming(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(dockerFrame(1, "hello "))
+	stream.Write(dockerFrame(2, "world\nsecond"))
+	stream.Write(dockerFrame(1, ""))
+	stream.Write(dockerFrame(1, " line\n"))
+
+	r := dockerLogReader(&stream, false)
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world\nsecond line\n" {
+		t.Fatalf("unexpected demuxed output %q", data)
+	}
+
+	// 帧不完整时返回错误
+	truncated := dockerFrame(1, "abcdef")[:10]
+	r = dockerLogReader(bytes.NewReader(truncated), false)
+	defer r.Close()
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatal("expected error for truncated frame")
+	}
+
+	// 分配 TTY 时原样返回
+	r = dockerLogReader(strings.NewReader("raw\n"), true)
+	defer r.Close()
+	if data, _ := io.ReadAll(r); string(data) != "raw\n" {
+		t.Fatalf("unexpected tty output %q", data)
+	}
+}
+
+func TestDockerLogReaderCloseStopsWriter(t *testing.T) {
+	base := runtime.NumGoroutine()
+	// 消费方一直不读取，解帧 goroutine 阻塞在写入上
+	r := dockerLogReader(bytes.NewReader(dockerFrame(1, "never read\n")), false)
+	r.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > base {
+		if time.Now().After(deadline) {
+			t.Fatalf("log reader goroutine still running after Close (%d > %d)", runtime.NumGoroutine(), base)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDockerManagerVerify(t *testing.T) {
+	fake, socket := startFakeDocker(t)
+	configPath := filepath.Join(t.TempDir(), "frps.toml")
+	if err := os.WriteFile(configPath, []byte("bindPort = 7000\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	m := NewDockerManager(socket, "frps", configPath, 0)
+
+	if err := m.Verify(configPath); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if fake.archive["/"+strings.TrimPrefix(dockerVerifyConfigPath, "/")] != "bindPort = 7000\n" {
+		t.Errorf("config not copied into verify container: %v", fake.archive)
+	}
+	if fake.created["Image"] != "snowdreamtech/frps:latest" {
+		t.Errorf("verify container image = %v", fake.created["Image"])
+	}
+	entrypoint, _ := fake.created["Entrypoint"].([]interface{})
+	if len(entrypoint) != 1 || entrypoint[0] != "/usr/bin/frps" {
+		t.Errorf("verify entrypoint = %v", fake.created["Entrypoint"])
+	}
+	hostConfig, _ := fake.created["HostConfig"].(map[string]interface{})
+	volumes, _ := hostConfig["VolumesFrom"].([]interface{})
+	if len(volumes) != 1 || volumes[0] != "frps-id:ro" {
+		t.Errorf("verify VolumesFrom = %v", hostConfig["VolumesFrom"])
+	}
+	if !fake.deleted {
+		t.Error("verify container was not removed")
+	}
+
+	fake.mu.Lock()
+	fake.verifyExit, fake.verifyLogs, fake.deleted = 1, "invalid bindPort\n", false
+	fake.mu.Unlock()
+	err := m.Verify(configPath)
+	if err == nil || !strings.Contains(err.Error(), "invalid bindPort") {
+		t.Fatalf("expected verify failure with container output, got %v", err)
+	}
+	if !fake.deleted {
+		t.Error("verify container was not removed after failure")
+	}
+}
@@ -0,0 +1,359 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 单个日志文件的最大大小，超过后轮转
+	DefaultFrpsLogMaxSize = 10 << 20
+	// 保留的日志文件数量（包括当前文件）
+	DefaultFrpsLogMaxFiles = 5
+
+	frpsLogFileName = "frps.log"
+	// 采集时添加的时间前缀，与内存日志格式一致
+	frpsCaptureTimeLayout = "2006-01-02 15:04:05"
+)
+
+// FrpsLogEntry 解析后的一行 frps 日志
+type FrpsLogEntry struct {
+	Time      time.Time `json:"time"`
+	Level     string    `json:"level"`     // trace, debug, info, warn, error，无法解析时为空
+	Component string    `json:"component"` // 输出日志的源文件位置，如 server/service.go:575
+	Message   string    `json:"message"`
+	Raw       string    `json:"raw"`
+}
+
+// FrpsLogQuery 日志查询条件，零值表示不限制
+type FrpsLogQuery struct {
+	Level  string // 最低级别
+	Since  time.Time
+	Until  time.Time
+	Search string // 不区分大小写的文本搜索
+	Proxy  string // 代理名称，frps 日志中为 [user.name] 形式
+	User   string // 客户端 user，匹配 [user.xxx] 中的前缀
+	Offset int
+	Limit  int
+}
+
+// FrpsLogPage 查询结果，按时间倒序
+// 日志文件查询凑够一页后不再读取更旧的文件，此时 Truncated 为 true，Total 只统计了已读取的文件
+type FrpsLogPage struct {
+	Entries   []FrpsLogEntry `json:"entries"`
+	Total     int            `json:"total"`
+	Truncated bool           `json:"truncated"`
+	Offset    int            `json:"offset"`
+	Limit     int            `json:"limit"`
+}
+
+var (
+	ansiColorRe    = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	captureTimeRe  = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\] `)
+	frpLogLineRe   = regexp.MustCompile(`^(\d{4}[-/]\d{2}[-/]\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?) \[([TDIWE])\] \[([^\]]+)\] ?(.*)$`)
+	journalLineRe  = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4}) \S+ [^:]+: (.*)$`)
+	frpLevelNames  = map[string]string{"T": "trace", "D": "debug", "I": "info", "W": "warn", "E": "error"}
+	frpLevelOrders = map[string]int{"trace": 0, "debug": 1, "info": 2, "warn": 3, "error": 4}
+)
+
+// ParseFrpsLogLine 解析 frp 日志格式：2024-01-02 15:04:05.123 [I] [server/service.go:575] message
+// 支持采集时添加的 [时间] 前缀和 journalctl short-iso 前缀，日志自身没有时间时使用前缀中的时间
+func ParseFrpsLogLine(line string) FrpsLogEntry {
+	line = ansiColorRe.ReplaceAllString(line, "")
+	entry := FrpsLogEntry{Raw: line}
+	rest := line
+
+	if m := captureTimeRe.FindStringSubmatch(rest); m != nil {
+		entry.Time, _ = time.ParseInLocation(frpsCaptureTimeLayout, m[1], time.Local)
+		rest = rest[len(m[0]):]
+	} else if m := journalLineRe.FindStringSubmatch(rest); m != nil {
+		entry.Time, _ = time.Parse("2006-01-02T15:04:05-0700", m[1])
+		rest = m[2]
+	}
+
+	m := frpLogLineRe.FindStringSubmatch(rest)
+	if m == nil {
+		entry.Message = rest
+		return entry
+	}
+	ts := strings.ReplaceAll(m[1], "/", "-")
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05.999999999", ts, time.Local); err == nil {
+		entry.Time = t
+	}
+	entry.Level = frpLevelNames[m[2]]
+	entry.Component = m[3]
+	entry.Message = m[4]
+	return entry
+}
+
+// ValidFrpsLogLevel 判断是否为支持的日志级别
+func ValidFrpsLogLevel(level string) bool {
+	_, ok := frpLevelOrders[level]
+	return ok
+}
+
+// Match 判断日志是否满足查询条件（不包括分页）
+func (q *FrpsLogQuery) Match(e *FrpsLogEntry) bool {
+	if q.Level != "" {
+		order, ok := frpLevelOrders[e.Level]
+		if !ok || order < frpLevelOrders[q.Level] {
+			return false
+		}
+	}
+	if !e.Time.IsZero() {
+		if !q.Since.IsZero() && e.Time.Before(q.Since) {
+			return false
+		}
+		if !q.Until.IsZero() && e.Time.After(q.Until) {
+			return false
+		}
+	}
+	if q.Search != "" && !strings.Contains(strings.ToLower(e.Raw), strings.ToLower(q.Search)) {
+		return false
+	}
+	if q.Proxy != "" && !strings.Contains(e.Message, "["+q.Proxy+"]") && !strings.Contains(e.Message, "."+q.Proxy+"]") {
+		return false
+	}
+	if q.User != "" && !strings.Contains(e.Message, "["+q.User+".") {
+		return false
+	}
+	return true
+}
+
+// queryFrpsLogLines 从新到旧解析按时间顺序排列的日志行，统计匹配总数，只保留当前页的日志
+func queryFrpsLogLines(lines []string, q FrpsLogQuery) *FrpsLogPage {
+	page := &FrpsLogPage{Entries: []FrpsLogEntry{}, Offset: q.Offset, Limit: q.Limit}
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		entry := ParseFrpsLogLine(lines[i])
+		if !q.Match(&entry) {
+			continue
+		}
+		if page.Total >= q.Offset && len(page.Entries) < q.Limit {
+			page.Entries = append(page.Entries, entry)
+		}
+		page.Total++
+	}
+	return page
+}
+
+// frpsLogRing 按时间顺序扫描时只保留最近的 size 条日志
+type frpsLogRing struct {
+	entries []FrpsLogEntry
+	size    int
+	next    int
+}
+
+func (r *frpsLogRing) add(e FrpsLogEntry) {
+	if r.size <= 0 {
+		return
+	}
+	if len(r.entries) < r.size {
+		r.entries = append(r.entries, e)
+		return
+	}
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % r.size
+}
+
+// appendNewestFirst 将保留的日志按从新到旧的顺序追加到 dst
+func (r *frpsLogRing) appendNewestFirst(dst []FrpsLogEntry) []FrpsLogEntry {
+	n := len(r.entries)
+	for i := 1; i <= n; i++ {
+		dst = append(dst, r.entries[(r.next-i+n)%n])
+	}
+	return dst
+}
+
+// FrpsLogStore 将采集到的 frps 输出写入按大小轮转的日志文件
+// 当前文件为 frps.log，轮转后依次为 frps.log.1、frps.log.2 ...
+type FrpsLogStore struct {
+	mu       sync.Mutex
+	dir      string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func NewFrpsLogStore(dir string, maxSize int64, maxFiles int) (*FrpsLogStore, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultFrpsLogMaxSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultFrpsLogMaxFiles
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create log dir failed: %v", err)
+	}
+	s := &FrpsLogStore{dir: dir, maxSize: maxSize, maxFiles: maxFiles}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FrpsLogStore) path(index int) string {
+	if index == 0 {
+		return filepath.Join(s.dir, frpsLogFileName)
+	}
+	return filepath.Join(s.dir, fmt.Sprintf("%s.%d", frpsLogFileName, index))
+}
+
+func (s *FrpsLogStore) open() error {
+	f, err := os.OpenFile(s.path(0), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open log file failed: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// Append 写入一行日志，文件超过大小限制时先轮转
+func (s *FrpsLogStore) Append(line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := line + "\n"
+	if s.size > 0 && s.size+int64(len(data)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if s.file == nil {
+		return fmt.Errorf("log file not open")
+	}
+	n, err := s.file.WriteString(data)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FrpsLogStore) rotate() error {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	os.Remove(s.path(s.maxFiles - 1))
+	for i := s.maxFiles - 2; i >= 0; i-- {
+		if _, err := os.Stat(s.path(i)); err == nil {
+			os.Rename(s.path(i), s.path(i+1))
+		}
+	}
+	return s.open()
+}
+
+// Query 从新到旧逐个读取日志文件并按条件查询，凑够 offset+limit 条后不再读取更旧的文件。
+// 每个文件内按时间顺序扫描，只用环形缓冲保留还需要的最近几条，内存占用与文件大小无关。
+// 只在锁内打开文件并记下当前文件的大小，扫描在锁外进行，避免长时间阻塞 Append；
+// 已打开的文件在轮转重命名后仍可读取，得到的是查询开始时的快照
+func (s *FrpsLogStore) Query(q FrpsLogQuery) (*FrpsLogPage, error) {
+	var readers []io.Reader
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	s.mu.Lock()
+	for i := 0; i < s.maxFiles; i++ {
+		f, err := os.Open(s.path(i))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			s.mu.Unlock()
+			return nil, err
+		}
+		files = append(files, f)
+		if i == 0 {
+			readers = append(readers, io.LimitReader(f, s.size))
+		} else {
+			readers = append(readers, f)
+		}
+	}
+	s.mu.Unlock()
+
+	page := &FrpsLogPage{Entries: []FrpsLogEntry{}, Offset: q.Offset, Limit: q.Limit}
+	need := q.Offset + q.Limit
+	var newest []FrpsLogEntry // 已收集的匹配日志，从新到旧
+	for _, r := range readers {
+		if len(newest) >= need {
+			page.Truncated = true
+			break
+		}
+		ring := &frpsLogRing{size: need - len(newest)}
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			entry := ParseFrpsLogLine(scanner.Text())
+			if q.Match(&entry) {
+				ring.add(entry)
+				page.Total++
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		newest = ring.appendNewestFirst(newest)
+	}
+	if q.Offset < len(newest) {
+		page.Entries = newest[q.Offset:]
+	}
+	return page, nil
+}
+
+// frpsLogHub 将新采集的日志行分发给实时订阅者
+type frpsLogHub struct {
+	mu          sync.Mutex
+	subscribers map[chan string]struct{}
+}
+
+// 订阅者的缓冲行数，消费过慢时丢弃新日志而不阻塞采集
+const frpsLogSubscriberBuffer = 256
+
+func (h *frpsLogHub) subscribe(ctx context.Context) <-chan string {
+	ch := make(chan string, frpsLogSubscriberBuffer)
+	h.mu.Lock()
+	if h.subscribers == nil {
+		h.subscribers = make(map[chan string]struct{})
+	}
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.subscribers, ch)
+		close(ch)
+		h.mu.Unlock()
+	}()
+	return ch
+}
+
+func (h *frpsLogHub) publish(line string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
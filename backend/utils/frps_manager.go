@@ -2,9 +2,15 @@ package utils
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net"
 	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -14,12 +20,17 @@ import (
 // FrpsManagerInterface 定义 frps 管理接口
 type FrpsManagerInterface interface {
 	Start() error
-	Stop() error
-	Restart() error
+	Stop() (*FrpsLifecycleResult, error)
+	Restart() (*FrpsLifecycleResult, error)
 	Status() FrpsStatus
 	Verify(configPath string) error
 	GetLogs(lines int) []string
+	QueryLogs(q FrpsLogQuery) (*FrpsLogPage, error)
+	// FollowLogs 实时返回新的日志行，ctx 结束时关闭 channel
+	FollowLogs(ctx context.Context) (<-chan string, error)
 	GetManagerType() string
+	// SetFrpsPath 切换 frps 可执行文件，下次启动或验证时生效
+	SetFrpsPath(path string)
 }
 
 type FrpsStatus struct {
@@ -27,19 +38,53 @@ type FrpsStatus struct {
 	PID         int       `json:"pid"`
 	StartTime   time.Time `json:"start_time,omitempty"`
 	Uptime      string    `json:"uptime,omitempty"`
-	ManagerType string    `json:"manager_type"` // process 或 systemctl
+	ManagerType string    `json:"manager_type"` // process、systemctl 或 docker
+	// 进程模式的守护状态
+	Supervised  bool       `json:"supervised,omitempty"`
+	Detached    bool       `json:"detached,omitempty"`
+	Adopted     bool       `json:"adopted,omitempty"` // 当前进程是 frp-admin 启动时通过 PID 文件接管的
+	Restarts    int        `json:"restarts,omitempty"`
+	CrashLoop   bool       `json:"crash_loop,omitempty"`
+	NextRestart *time.Time `json:"next_restart,omitempty"`
+	LastCrash   *FrpsCrash `json:"last_crash,omitempty"`
 }
 
+// FrpsLifecycleResult 停止或重启的结果和各阶段耗时
+type FrpsLifecycleResult struct {
+	Action     string `json:"action"` // stop 或 restart
+	PID        int    `json:"pid,omitempty"`
+	Signal     string `json:"signal,omitempty"` // 最终使进程退出的信号
+	Escalated  bool   `json:"escalated"`        // 宽限期内未退出，已发送 SIGKILL
+	StopMs     int64  `json:"stop_ms"`
+	PortWaitMs int64  `json:"port_wait_ms,omitempty"`
+	StartMs    int64  `json:"start_ms,omitempty"`
+	NewPID     int    `json:"new_pid,omitempty"`
+	TotalMs    int64  `json:"total_ms"`
+}
+
+const (
+	// 停止时等待 frps 退出的默认宽限期，超时后发送 SIGKILL
+	DefaultFrpsStopTimeout = 10 * time.Second
+	// 发送 SIGKILL 后等待退出的时间
+	frpsKillTimeout = 5 * time.Second
+	// 重启前等待 bindPort 释放时的检查间隔
+	frpsPortPollInterval = 100 * time.Millisecond
+)
+
 var (
 	manager FrpsManagerInterface
 )
 
 // InitFrpsManager 根据配置初始化对应的管理器
-func InitFrpsManager(frpsPath, configPath, managerType, serviceName string) FrpsManagerInterface {
-	if managerType == "systemctl" {
+// serviceName 在 systemctl 模式下为服务名，在 docker 模式下为容器名；opts 除 StopTimeout 外仅用于进程模式
+func InitFrpsManager(frpsPath, configPath, managerType, serviceName, dockerSocket string, opts ProcessManagerOptions) FrpsManagerInterface {
+	switch managerType {
+	case "systemctl":
 		manager = NewSystemctlManager(frpsPath, configPath, serviceName)
-	} else {
-		manager = NewProcessManager(frpsPath, configPath)
+	case "docker":
+		manager = NewDockerManager(dockerSocket, serviceName, configPath, opts.StopTimeout)
+	default:
+		manager = NewProcessManager(frpsPath, configPath, opts)
 	}
 	return manager
 }
@@ -50,31 +95,69 @@ func GetFrpsManager() FrpsManagerInterface {
 
 // ============= 进程管理模式 =============
 
+// ProcessManagerOptions 进程模式的可选功能
+type ProcessManagerOptions struct {
+	LogDir    string // 保存 frps 输出的目录，为空时只保留内存中的日志
+	Supervise bool   // frps 意外退出后按指数退避自动重启
+	Detach    bool   // 在独立进程组中运行，frp-admin 退出后 frps 继续运行，重启后通过 PID 文件重新接管
+	PIDFile   string // Detach 模式下记录 frps PID 的文件
+	// 停止时等待进程退出的宽限期，为 0 时使用 DefaultFrpsStopTimeout
+	StopTimeout time.Duration
+}
+
+// frpsRun 一次启动（或接管）的 frps 进程
+type frpsRun struct {
+	cmd       *exec.Cmd // 接管的进程为 nil
+	pid       int
+	startTime time.Time
+	adopted   bool
+	stopping  bool          // 通过 Stop 主动停止，退出时不视为崩溃
+	done      chan struct{} // 进程退出后关闭
+}
+
 type ProcessManager struct {
-	cmd         *exec.Cmd
 	frpsPath    string
 	configPath  string
+	opts        ProcessManagerOptions
 	running     bool
+	run         *frpsRun
 	mu          sync.RWMutex
 	logLines    []string
 	maxLogLines int
+	stderrTail  []string // 当前进程最近的 stderr 输出，崩溃时记录
 	logMu       sync.RWMutex
-	startTime   time.Time
+	logStore    *FrpsLogStore // 持久化的日志文件，创建失败时只保留内存中的日志
+	logHub      frpsLogHub
+	supervisor  frpsSupervisor
 }
 
-func NewProcessManager(frpsPath, configPath string) *ProcessManager {
-	return &ProcessManager{
+func NewProcessManager(frpsPath, configPath string, opts ProcessManagerOptions) *ProcessManager {
+	m := &ProcessManager{
 		frpsPath:    frpsPath,
 		configPath:  configPath,
+		opts:        opts,
 		maxLogLines: 1000,
 		logLines:    make([]string, 0),
 	}
+	if opts.LogDir != "" {
+		store, err := NewFrpsLogStore(opts.LogDir, DefaultFrpsLogMaxSize, DefaultFrpsLogMaxFiles)
+		if err != nil {
+			log.Printf("frps log store disabled: %v", err)
+		} else {
+			m.logStore = store
+		}
+	}
+	if opts.Detach {
+		m.adopt()
+	}
+	return m
 }
 
 func (m *ProcessManager) GetManagerType() string {
 	return "process"
 }
 
+// Start 手动启动 frps，同时清除崩溃循环状态和等待中的自动重启
 func (m *ProcessManager) Start() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -82,61 +165,123 @@ func (m *ProcessManager) Start() error {
 	if m.running {
 		return fmt.Errorf("frps is already running")
 	}
+	m.supervisor.reset()
+	return m.startLocked()
+}
 
+func (m *ProcessManager) startLocked() error {
 	if _, err := os.Stat(m.configPath); os.IsNotExist(err) {
 		return fmt.Errorf("config file not found: %s", m.configPath)
 	}
 
-	m.cmd = exec.Command(m.frpsPath, "-c", m.configPath)
-
-	stdout, err := m.cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to get stdout pipe: %v", err)
-	}
-	stderr, err := m.cmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to get stderr pipe: %v", err)
+	cmd := exec.Command(m.frpsPath, "-c", m.configPath)
+	run := &frpsRun{cmd: cmd, done: make(chan struct{})}
+
+	// 进程退出后关闭，通知输出文件读取结束
+	exited := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(2)
+	var startReaders func()
+	if m.opts.Detach {
+		// 独立进程组，输出写入文件而不是管道，frp-admin 退出后 frps 不会因 SIGPIPE 退出
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		stdout, err := openFrpsOutput(m.outputPath("stdout"))
+		if err != nil {
+			return err
+		}
+		defer stdout.Close()
+		stderr, err := openFrpsOutput(m.outputPath("stderr"))
+		if err != nil {
+			return err
+		}
+		defer stderr.Close()
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		startReaders = func() {
+			go func() { defer readers.Done(); m.tailOutput(m.outputPath("stdout"), false, false, exited) }()
+			go func() { defer readers.Done(); m.tailOutput(m.outputPath("stderr"), false, true, exited) }()
+		}
+	} else {
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return fmt.Errorf("failed to get stdout pipe: %v", err)
+		}
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			return fmt.Errorf("failed to get stderr pipe: %v", err)
+		}
+		startReaders = func() {
+			go func() { defer readers.Done(); m.readLogs(stdout, false) }()
+			go func() { defer readers.Done(); m.readLogs(stderr, true) }()
+		}
 	}
 
-	if err := m.cmd.Start(); err != nil {
+	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start frps: %v", err)
 	}
 
+	run.pid = cmd.Process.Pid
+	run.startTime = time.Now()
+	m.run = run
 	m.running = true
-	m.startTime = time.Now()
-
-	go m.readLogs(stdout)
-	go m.readLogs(stderr)
+	m.logMu.Lock()
+	m.stderrTail = nil
+	m.logMu.Unlock()
+	if m.opts.Detach {
+		m.writePIDFile(run.pid)
+	}
 
+	startReaders()
 	go func() {
-		m.cmd.Wait()
-		m.mu.Lock()
-		m.running = false
-		m.mu.Unlock()
+		// 管道需要先读完再 Wait，否则会丢失退出前最后的输出
+		if !m.opts.Detach {
+			readers.Wait()
+		}
+		cmd.Wait()
+		close(exited)
+		readers.Wait()
+		exitCode, signal := -1, ""
+		if state := cmd.ProcessState; state != nil {
+			exitCode = state.ExitCode()
+			if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+				signal = ws.Signal().String()
+			}
+		}
+		m.handleExit(run, exitCode, signal)
 	}()
 
 	return nil
 }
 
-func (m *ProcessManager) readLogs(reader interface{ Read([]byte) (int, error) }) {
+func (m *ProcessManager) readLogs(reader interface{ Read([]byte) (int, error) }, stderr bool) {
 	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
-		line := scanner.Text()
-		m.addLogLine(line)
+		m.addLogLine(scanner.Text(), stderr)
 	}
 }
 
-func (m *ProcessManager) addLogLine(line string) {
-	m.logMu.Lock()
-	defer m.logMu.Unlock()
-
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
+func (m *ProcessManager) addLogLine(line string, stderr bool) {
+	timestamp := time.Now().Format(frpsCaptureTimeLayout)
 	logLine := fmt.Sprintf("[%s] %s", timestamp, line)
 
+	m.logMu.Lock()
 	m.logLines = append(m.logLines, logLine)
 	if len(m.logLines) > m.maxLogLines {
 		m.logLines = m.logLines[1:]
 	}
+	if stderr {
+		m.stderrTail = append(m.stderrTail, line)
+		if len(m.stderrTail) > crashStderrLines {
+			m.stderrTail = m.stderrTail[1:]
+		}
+	}
+	m.logMu.Unlock()
+
+	m.logHub.publish(logLine)
+	if m.logStore != nil {
+		if err := m.logStore.Append(logLine); err != nil {
+			log.Printf("write frps log failed: %v", err)
+		}
+	}
 }
 
 func (m *ProcessManager) GetLogs(lines int) []string {
@@ -157,35 +302,99 @@ func (m *ProcessManager) GetLogs(lines int) []string {
 	return result
 }
 
-func (m *ProcessManager) Stop() error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if !m.running || m.cmd == nil || m.cmd.Process == nil {
-		return fmt.Errorf("frps is not running")
+// QueryLogs 查询持久化的日志文件，未启用时查询内存中的日志
+func (m *ProcessManager) QueryLogs(q FrpsLogQuery) (*FrpsLogPage, error) {
+	if m.logStore != nil {
+		return m.logStore.Query(q)
 	}
+	return queryFrpsLogLines(m.GetLogs(0), q), nil
+}
+
+// FollowLogs 订阅 frps 进程新输出的日志
+func (m *ProcessManager) FollowLogs(ctx context.Context) (<-chan string, error) {
+	return m.logHub.subscribe(ctx), nil
+}
 
-	if err := m.cmd.Process.Signal(syscall.SIGTERM); err != nil {
-		m.cmd.Process.Kill()
+// Stop 停止 frps 并等待进程退出，宽限期内未退出时发送 SIGKILL，同时取消等待中的自动重启
+func (m *ProcessManager) Stop() (*FrpsLifecycleResult, error) {
+	begin := time.Now()
+	result := &FrpsLifecycleResult{Action: "stop"}
+	if err := m.stop(result); err != nil {
+		return nil, err
 	}
+	result.TotalMs = time.Since(begin).Milliseconds()
+	return result, nil
+}
 
-	m.running = false
+func (m *ProcessManager) stop(result *FrpsLifecycleResult) error {
+	m.mu.Lock()
+	restartPending := m.supervisor.cancelRestart()
+	if !m.running || m.run == nil {
+		m.mu.Unlock()
+		if restartPending {
+			return nil
+		}
+		return fmt.Errorf("frps is not running")
+	}
+	run := m.run
+	run.stopping = true
+	// 等待退出时不能持有锁，handleExit 需要加锁清理状态
+	m.mu.Unlock()
+
+	begin := time.Now()
+	result.PID = run.pid
+	result.Signal = "SIGTERM"
+	syscall.Kill(run.pid, syscall.SIGTERM)
+
+	grace := m.opts.StopTimeout
+	if grace <= 0 {
+		grace = DefaultFrpsStopTimeout
+	}
+	select {
+	case <-run.done:
+	case <-time.After(grace):
+		log.Printf("frps (pid %d) did not exit within %s, sending SIGKILL", run.pid, grace)
+		result.Signal = "SIGKILL"
+		result.Escalated = true
+		syscall.Kill(run.pid, syscall.SIGKILL)
+		select {
+		case <-run.done:
+		case <-time.After(frpsKillTimeout):
+			return fmt.Errorf("frps (pid %d) did not exit after SIGKILL", run.pid)
+		}
+	}
+	result.StopMs = time.Since(begin).Milliseconds()
 	return nil
 }
 
-func (m *ProcessManager) Restart() error {
+// Restart 停止 frps 后确认 bindPort 已释放再启动，避免新进程与旧进程争用端口
+func (m *ProcessManager) Restart() (*FrpsLifecycleResult, error) {
+	begin := time.Now()
+	result := &FrpsLifecycleResult{Action: "restart"}
+
 	m.mu.RLock()
 	wasRunning := m.running
 	m.mu.RUnlock()
-
 	if wasRunning {
-		if err := m.Stop(); err != nil {
-			return err
+		if err := m.stop(result); err != nil {
+			return nil, err
 		}
-		time.Sleep(time.Second)
 	}
 
-	return m.Start()
+	waitBegin := time.Now()
+	if err := waitFrpsPortFree(m.configPath, m.opts.StopTimeout); err != nil {
+		return nil, err
+	}
+	result.PortWaitMs = time.Since(waitBegin).Milliseconds()
+
+	startBegin := time.Now()
+	if err := m.Start(); err != nil {
+		return nil, err
+	}
+	result.StartMs = time.Since(startBegin).Milliseconds()
+	result.NewPID = m.Status().PID
+	result.TotalMs = time.Since(begin).Milliseconds()
+	return result, nil
 }
 
 func (m *ProcessManager) Status() FrpsStatus {
@@ -195,19 +404,44 @@ func (m *ProcessManager) Status() FrpsStatus {
 	status := FrpsStatus{
 		Running:     m.running,
 		ManagerType: "process",
+		Supervised:  m.opts.Supervise,
+		Detached:    m.opts.Detach,
 	}
+	m.supervisor.fillStatus(&status)
 
-	if m.running && m.cmd != nil && m.cmd.Process != nil {
-		status.PID = m.cmd.Process.Pid
-		status.StartTime = m.startTime
-		status.Uptime = time.Since(m.startTime).Round(time.Second).String()
+	if m.running && m.run != nil {
+		status.PID = m.run.pid
+		status.StartTime = m.run.startTime
+		status.Uptime = time.Since(m.run.startTime).Round(time.Second).String()
+		status.Adopted = m.run.adopted
 	}
 
 	return status
 }
 
+// Crashes 返回最近记录的意外退出，按时间倒序
+func (m *ProcessManager) Crashes() []FrpsCrash {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.supervisor.history()
+}
+
+func (m *ProcessManager) SetFrpsPath(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.frpsPath = path
+}
+
 func (m *ProcessManager) Verify(configPath string) error {
-	cmd := exec.Command(m.frpsPath, "verify", "-c", configPath)
+	m.mu.RLock()
+	frpsPath := m.frpsPath
+	m.mu.RUnlock()
+	return VerifyFrpsConfig(frpsPath, configPath)
+}
+
+// VerifyFrpsConfig 使用指定的 frps 执行 frps verify
+func VerifyFrpsConfig(frpsPath, configPath string) error {
+	cmd := exec.Command(frpsPath, "verify", "-c", configPath)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("config verification failed: %s", string(output))
@@ -224,6 +458,7 @@ type SystemctlManager struct {
 	logLines    []string
 	maxLogLines int
 	logMu       sync.RWMutex
+	pathMu      sync.RWMutex
 }
 
 func NewSystemctlManager(frpsPath, configPath, serviceName string) *SystemctlManager {
@@ -249,22 +484,43 @@ func (m *SystemctlManager) Start() error {
 	return nil
 }
 
-func (m *SystemctlManager) Stop() error {
+// Stop systemctl stop 会等待服务退出，超时和 SIGKILL 由服务的 TimeoutStopSec 控制
+func (m *SystemctlManager) Stop() (*FrpsLifecycleResult, error) {
+	begin := time.Now()
+	result := &FrpsLifecycleResult{Action: "stop", PID: m.Status().PID}
 	cmd := exec.Command("systemctl", "stop", m.serviceName)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("failed to stop %s: %s", m.serviceName, string(output))
+		return nil, fmt.Errorf("failed to stop %s: %s", m.serviceName, string(output))
 	}
-	return nil
+	result.StopMs = time.Since(begin).Milliseconds()
+	result.TotalMs = result.StopMs
+	return result, nil
 }
 
-func (m *SystemctlManager) Restart() error {
-	cmd := exec.Command("systemctl", "restart", m.serviceName)
-	output, err := cmd.CombinedOutput()
+// Restart 分别执行 stop 和 start，中间确认 bindPort 已释放
+func (m *SystemctlManager) Restart() (*FrpsLifecycleResult, error) {
+	begin := time.Now()
+	result, err := m.Stop()
 	if err != nil {
-		return fmt.Errorf("failed to restart %s: %s", m.serviceName, string(output))
+		return nil, err
 	}
-	return nil
+	result.Action = "restart"
+
+	waitBegin := time.Now()
+	if err := waitFrpsPortFree(m.configPath, DefaultFrpsStopTimeout); err != nil {
+		return nil, err
+	}
+	result.PortWaitMs = time.Since(waitBegin).Milliseconds()
+
+	startBegin := time.Now()
+	if err := m.Start(); err != nil {
+		return nil, err
+	}
+	result.StartMs = time.Since(startBegin).Milliseconds()
+	result.NewPID = m.Status().PID
+	result.TotalMs = time.Since(begin).Milliseconds()
+	return result, nil
 }
 
 func (m *SystemctlManager) Status() FrpsStatus {
@@ -304,13 +560,18 @@ func (m *SystemctlManager) Status() FrpsStatus {
 	return status
 }
 
+// SetFrpsPath 只影响配置验证，服务实际运行的可执行文件由 unit 的 ExecStart 决定
+func (m *SystemctlManager) SetFrpsPath(path string) {
+	m.pathMu.Lock()
+	defer m.pathMu.Unlock()
+	m.frpsPath = path
+}
+
 func (m *SystemctlManager) Verify(configPath string) error {
-	cmd := exec.Command(m.frpsPath, "verify", "-c", configPath)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("config verification failed: %s", string(output))
-	}
-	return nil
+	m.pathMu.RLock()
+	frpsPath := m.frpsPath
+	m.pathMu.RUnlock()
+	return VerifyFrpsConfig(frpsPath, configPath)
 }
 
 func (m *SystemctlManager) GetLogs(lines int) []string {
@@ -324,3 +585,92 @@ func (m *SystemctlManager) GetLogs(lines int) []string {
 	logLines := strings.Split(strings.TrimSpace(string(output)), "\n")
 	return logLines
 }
+
+// journalctl 单次查询读取的最大行数
+const maxJournalLines = 20000
+
+// QueryLogs 通过 journalctl 的 --since/--until/--grep 查询，级别过滤和分页在解析后进行
+func (m *SystemctlManager) QueryLogs(q FrpsLogQuery) (*FrpsLogPage, error) {
+	args := []string{"-u", m.serviceName, "--no-pager", "-o", "short-iso", "-n", fmt.Sprintf("%d", maxJournalLines)}
+	if !q.Since.IsZero() {
+		args = append(args, "--since", q.Since.Local().Format("2006-01-02 15:04:05"))
+	}
+	if !q.Until.IsZero() {
+		args = append(args, "--until", q.Until.Local().Format("2006-01-02 15:04:05"))
+	}
+	if q.Search != "" {
+		args = append(args, "--grep", regexp.QuoteMeta(q.Search))
+	}
+
+	output, err := exec.Command("journalctl", args...).Output()
+	if err != nil && len(output) == 0 {
+		// --grep 没有匹配时 journalctl 以非零状态退出
+		if _, ok := err.(*exec.ExitError); ok && q.Search != "" {
+			return queryFrpsLogLines(nil, q), nil
+		}
+		return nil, fmt.Errorf("journalctl failed: %v", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		// 跳过 "-- No entries --"、"-- Boot ... --" 等提示行
+		if strings.HasPrefix(line, "-- ") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return queryFrpsLogLines(lines, q), nil
+}
+
+// FollowLogs 通过 journalctl -f 跟踪服务的新日志
+func (m *SystemctlManager) FollowLogs(ctx context.Context) (<-chan string, error) {
+	cmd := exec.CommandContext(ctx, "journalctl", "-u", m.serviceName, "-f", "-n", "0", "--no-pager", "-o", "short-iso")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("journalctl failed: %v", err)
+	}
+
+	ch := make(chan string, frpsLogSubscriberBuffer)
+	go func() {
+		defer close(ch)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			select {
+			case ch <- scanner.Text():
+			case <-ctx.Done():
+			}
+		}
+		cmd.Wait()
+	}()
+	return ch, nil
+}
+
+// waitFrpsPortFree 等待 frps 配置中的 bindPort 可以监听，配置无法解析或没有权限监听时不检查
+func waitFrpsPortFree(configPath string, timeout time.Duration) error {
+	cfg, err := ParseFrpsToml(configPath)
+	if err != nil || cfg.BindPort <= 0 {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = DefaultFrpsStopTimeout
+	}
+	addr := net.JoinHostPort(cfg.BindAddr, strconv.Itoa(cfg.BindPort))
+	deadline := time.Now().Add(timeout)
+	for {
+		ln, err := net.Listen("tcp", addr)
+		if err == nil {
+			ln.Close()
+			return nil
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("bindPort %d is still in use after %s", cfg.BindPort, timeout)
+		}
+		time.Sleep(frpsPortPollInterval)
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FrpcAuthSecretMetaKey 生成的 frpc 配置中保存客户端密钥的 metadatas 键
+const FrpcAuthSecretMetaKey = "frp_admin_secret"
+
+// FrpsPluginRequest frps 服务端插件请求，content 按 op 解析
+type FrpsPluginRequest struct {
+	Version string          `json:"version"`
+	Op      string          `json:"op"`
+	Content json.RawMessage `json:"content"`
+}
+
+// FrpsPluginResponse frps 服务端插件响应
+type FrpsPluginResponse struct {
+	Reject       bool        `json:"reject"`
+	RejectReason string      `json:"reject_reason,omitempty"`
+	Unchange     bool        `json:"unchange"`
+	Content      interface{} `json:"content,omitempty"`
+}
+
+// FrpsPluginLoginContent Login 操作的请求内容
+type FrpsPluginLoginContent struct {
+	Version       string            `json:"version"`
+	Hostname      string            `json:"hostname"`
+	Os            string            `json:"os"`
+	Arch          string            `json:"arch"`
+	User          string            `json:"user"`
+	Timestamp     int64             `json:"timestamp"`
+	PrivilegeKey  string            `json:"privilege_key"`
+	RunID         string            `json:"run_id"`
+	PoolCount     int               `json:"pool_count"`
+	Metas         map[string]string `json:"metas"`
+	ClientAddress string            `json:"client_address"`
+}
+
+// FrpsPluginUserInfo 插件请求中发起操作的客户端信息
+type FrpsPluginUserInfo struct {
+	User  string            `json:"user"`
+	Metas map[string]string `json:"metas"`
+	RunID string            `json:"run_id"`
+}
+
+// FrpsPluginNewProxyContent NewProxy 操作的请求内容（只解析需要校验的字段）
+type FrpsPluginNewProxyContent struct {
+	User          FrpsPluginUserInfo `json:"user"`
+	ProxyName     string             `json:"proxy_name"`
+	ProxyType     string             `json:"proxy_type"`
+	Group         string             `json:"group"`
+	GroupKey      string             `json:"group_key"`
+	RemotePort    int                `json:"remote_port"`
+	CustomDomains []string           `json:"custom_domains"`
+	SubDomain     string             `json:"subdomain"`
+}
+
+// BuildFrpsPluginSnippet 生成 frps.toml 中的 [[httpPlugins]] 配置片段
+func BuildFrpsPluginSnippet(name, addr, path string, ops []string) (string, error) {
+	t := newTomlTable(false)
+	t.Set("name", name)
+	t.Set("addr", addr)
+	t.Set("path", path)
+	t.Set("ops", ops)
+
+	w := &tomlWriter{}
+	w.Comment("frp-admin 服务端插件（客户端独立密钥校验）")
+	w.ArrayTable("httpPlugins", t)
+	if w.err != nil {
+		return "", w.err
+	}
+	return w.buf.String(), nil
+}
+
+// AppendFrpsPlugin 将插件配置追加到 frps.toml 内容末尾
+func AppendFrpsPlugin(content, snippet string) (string, error) {
+	result := content
+	if result != "" && !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+	result += "\n" + snippet
+
+	if _, err := ParseFrpsTomlContent([]byte(result)); err != nil {
+		return "", fmt.Errorf("append plugin failed: %v", err)
+	}
+	return result, nil
+}
+
+// SetFrpsPluginOps 修改 path 对应的 [[httpPlugins]] 的 ops，保留其余内容
+func SetFrpsPluginOps(content, path string, ops []string) (string, error) {
+	literal, err := FormatTomlLiteral(toInterfaceSlice(ops))
+	if err != nil {
+		return "", err
+	}
+	editor := NewTomlEditor(content)
+	if !editor.SetInArrayTable("httpPlugins", "path", path, "ops", literal) {
+		return "", fmt.Errorf("plugin %s not found", path)
+	}
+
+	result := editor.String()
+	if _, err := ParseFrpsTomlContent([]byte(result)); err != nil {
+		return "", fmt.Errorf("update plugin failed: %v", err)
+	}
+	return result, nil
+}
+
+func toInterfaceSlice(items []string) []interface{} {
+	result := make([]interface{}, len(items))
+	for i, item := range items {
+		result[i] = item
+	}
+	return result
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// frps 可通过结构化接口编辑的配置项及其类型
+// string/int/bool 为标量，strings 为字符串数组，ports 为 allowPorts 端口范围数组
+var frpsEditableSettings = map[string]string{
+	"bindAddr":                   "string",
+	"bindPort":                   "int",
+	"kcpBindPort":                "int",
+	"quicBindPort":               "int",
+	"proxyBindAddr":              "string",
+	"vhostHTTPPort":              "int",
+	"vhostHTTPSPort":             "int",
+	"vhostHTTPTimeout":           "int",
+	"subDomainHost":              "string",
+	"tcpmuxHTTPConnectPort":      "int",
+	"tcpmuxPassthrough":          "bool",
+	"allowPorts":                 "ports",
+	"maxPortsPerClient":          "int",
+	"userConnTimeout":            "int",
+	"udpPacketSize":              "int",
+	"auth.method":                "string",
+	"auth.token":                 "string",
+	"auth.additionalScopes":      "strings",
+	"webServer.addr":             "string",
+	"webServer.port":             "int",
+	"webServer.user":             "string",
+	"webServer.password":         "string",
+	"transport.maxPoolCount":     "int",
+	"transport.heartbeatTimeout": "int",
+	"transport.tcpMux":           "bool",
+	"transport.tls.force":        "bool",
+	"log.to":                     "string",
+	"log.level":                  "string",
+	"log.maxDays":                "int",
+}
+
+// FrpsSettingKeys 返回所有可编辑的配置项及其类型
+func FrpsSettingKeys() map[string]string {
+	result := make(map[string]string, len(frpsEditableSettings))
+	for k, v := range frpsEditableSettings {
+		result[k] = v
+	}
+	return result
+}
+
+// GetFrpsSettings 读取 TOML 内容中所有可编辑配置项的当前值，未设置的项为 nil
+func GetFrpsSettings(content string) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, fmt.Errorf("parse frps config failed: %v", err)
+	}
+
+	result := make(map[string]interface{}, len(frpsEditableSettings))
+	for key := range frpsEditableSettings {
+		result[key] = lookupTomlPath(doc, key)
+	}
+	return result, nil
+}
+
+// ApplyFrpsSettings 将修改应用到 TOML 内容上，保留原有注释和顺序
+// 值为 nil 表示删除该配置项（恢复 frps 默认值）
+func ApplyFrpsSettings(content string, changes map[string]interface{}) (string, error) {
+	editor := NewTomlEditor(content)
+
+	keys := make([]string, 0, len(changes))
+	for k := range changes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []string
+	for _, key := range keys {
+		kind, ok := frpsEditableSettings[key]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s: 不支持编辑的配置项", key))
+			continue
+		}
+		value := changes[key]
+		if value == nil {
+			editor.Delete(key)
+			continue
+		}
+		if err := checkFrpsSettingValue(kind, value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		literal, err := FormatTomlLiteral(value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		editor.Set(key, literal)
+	}
+	if len(errs) > 0 {
+		return "", fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	// 确认编辑后的内容仍是合法的 TOML
+	result := editor.String()
+	if _, err := ParseFrpsTomlContent([]byte(result)); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// checkFrpsSettingValue 校验 JSON 解码得到的值是否符合配置项类型
+func checkFrpsSettingValue(kind string, value interface{}) error {
+	switch kind {
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("应为字符串")
+		}
+	case "bool":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("应为布尔值")
+		}
+	case "int":
+		if !isJSONInteger(value) {
+			return fmt.Errorf("应为整数")
+		}
+	case "strings":
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("应为字符串数组")
+		}
+		for _, item := range list {
+			if _, ok := item.(string); !ok {
+				return fmt.Errorf("应为字符串数组")
+			}
+		}
+	case "ports":
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("应为端口范围数组")
+		}
+		for _, item := range list {
+			r, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("端口范围应为 {start, end} 或 {single}")
+			}
+			for k, v := range r {
+				if k != "start" && k != "end" && k != "single" {
+					return fmt.Errorf("端口范围不支持字段 %s", k)
+				}
+				if !isJSONInteger(v) {
+					return fmt.Errorf("端口范围的 %s 应为整数", k)
+				}
+			}
+			_, hasSingle := r["single"]
+			_, hasStart := r["start"]
+			_, hasEnd := r["end"]
+			if hasSingle == (hasStart || hasEnd) || hasStart != hasEnd {
+				return fmt.Errorf("端口范围应为 {start, end} 或 {single}")
+			}
+		}
+	}
+	return nil
+}
+
+func isJSONInteger(v interface{}) bool {
+	switch n := v.(type) {
+	case int, int64:
+		return true
+	case float64:
+		return n == float64(int64(n))
+	}
+	return false
+}
+
+// lookupTomlPath 按点分路径在解码后的文档中查找值
+func lookupTomlPath(doc map[string]interface{}, path string) interface{} {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil
+		}
+	}
+	return current
+}
@@ -0,0 +1,319 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ============= 进程模式的守护：崩溃重启、退避和 PID 文件接管 =============
+
+const (
+	// 每次崩溃记录的 stderr 行数
+	crashStderrLines = 20
+	// 保留的崩溃记录数量
+	maxCrashHistory = 20
+
+	restartBackoffBase = time.Second
+	restartBackoffMax  = time.Minute
+	// 运行超过该时间后再崩溃，退避从头开始计算
+	stableRunTime = time.Minute
+	// 在窗口内崩溃达到阈值视为崩溃循环，停止自动重启直到手动启动
+	crashLoopWindow    = 5 * time.Minute
+	crashLoopThreshold = 5
+
+	// 接管的进程没有 Wait 可用，定期检查是否仍在运行
+	adoptedPollInterval = time.Second
+	outputPollInterval  = 300 * time.Millisecond
+)
+
+// FrpsCrash 一次意外退出的记录
+type FrpsCrash struct {
+	Time     time.Time `json:"time"`
+	ExitCode int       `json:"exit_code"` // -1 表示未知，如接管的进程或启动失败
+	Signal   string    `json:"signal,omitempty"`
+	Uptime   string    `json:"uptime"`
+	Stderr   []string  `json:"stderr"` // 退出前最后的 stderr 输出
+}
+
+// frpsSupervisor 崩溃记录和自动重启状态，由 ProcessManager.mu 保护
+type frpsSupervisor struct {
+	crashes     []FrpsCrash
+	restarts    int
+	consecutive int
+	crashLoop   bool
+	timer       *time.Timer
+	timerGen    int // 取消或重新计划后递增，已触发但尚未拿到锁的回调据此放弃
+	nextRestart time.Time
+}
+
+func (s *frpsSupervisor) reset() {
+	s.cancelRestart()
+	s.crashLoop = false
+	s.consecutive = 0
+}
+
+// cancelRestart 取消等待中的自动重启，返回是否存在等待中的重启
+func (s *frpsSupervisor) cancelRestart() bool {
+	pending := s.timer != nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.timerGen++
+	s.nextRestart = time.Time{}
+	return pending
+}
+
+func (s *frpsSupervisor) record(crash FrpsCrash) {
+	s.crashes = append(s.crashes, crash)
+	if len(s.crashes) > maxCrashHistory {
+		s.crashes = s.crashes[1:]
+	}
+}
+
+// nextDelay 计算下次重启前的等待时间，进入崩溃循环时返回 false
+func (s *frpsSupervisor) nextDelay(now time.Time, uptime time.Duration) (time.Duration, bool) {
+	recent := 0
+	for _, c := range s.crashes {
+		if now.Sub(c.Time) <= crashLoopWindow {
+			recent++
+		}
+	}
+	if recent >= crashLoopThreshold {
+		s.crashLoop = true
+		return 0, false
+	}
+
+	if uptime >= stableRunTime {
+		s.consecutive = 0
+	}
+	s.consecutive++
+	delay := restartBackoffMax
+	if s.consecutive <= 10 {
+		delay = restartBackoffBase << (s.consecutive - 1)
+	}
+	if delay > restartBackoffMax {
+		delay = restartBackoffMax
+	}
+	return delay, true
+}
+
+func (s *frpsSupervisor) schedule(delay time.Duration, fn func(gen int)) {
+	s.timerGen++
+	gen := s.timerGen
+	s.nextRestart = time.Now().Add(delay)
+	s.timer = time.AfterFunc(delay, func() { fn(gen) })
+}
+
+func (s *frpsSupervisor) fillStatus(status *FrpsStatus) {
+	status.Restarts = s.restarts
+	status.CrashLoop = s.crashLoop
+	if s.timer != nil {
+		next := s.nextRestart
+		status.NextRestart = &next
+	}
+	if len(s.crashes) > 0 {
+		last := s.crashes[len(s.crashes)-1]
+		status.LastCrash = &last
+	}
+}
+
+func (s *frpsSupervisor) history() []FrpsCrash {
+	result := make([]FrpsCrash, 0, len(s.crashes))
+	for i := len(s.crashes) - 1; i >= 0; i-- {
+		result = append(result, s.crashes[i])
+	}
+	return result
+}
+
+// handleExit 处理进程退出：主动停止时只清理状态，否则记录崩溃并按需安排重启
+func (m *ProcessManager) handleExit(run *frpsRun, exitCode int, signal string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	close(run.done)
+	if run != m.run {
+		return
+	}
+	m.running = false
+	m.run = nil
+	if m.opts.Detach {
+		os.Remove(m.opts.PIDFile)
+	}
+	if run.stopping {
+		return
+	}
+
+	now := time.Now()
+	uptime := now.Sub(run.startTime)
+	m.logMu.RLock()
+	stderr := append([]string(nil), m.stderrTail...)
+	m.logMu.RUnlock()
+	m.supervisor.record(FrpsCrash{
+		Time:     now,
+		ExitCode: exitCode,
+		Signal:   signal,
+		Uptime:   uptime.Round(time.Second).String(),
+		Stderr:   stderr,
+	})
+	log.Printf("frps exited unexpectedly (code %d %s) after %s", exitCode, signal, uptime.Round(time.Second))
+	m.scheduleRestartLocked(now, uptime)
+}
+
+func (m *ProcessManager) scheduleRestartLocked(now time.Time, uptime time.Duration) {
+	if !m.opts.Supervise {
+		return
+	}
+	delay, ok := m.supervisor.nextDelay(now, uptime)
+	if !ok {
+		log.Printf("frps crash loop detected (%d crashes in %s), automatic restart disabled until started manually", crashLoopThreshold, crashLoopWindow)
+		return
+	}
+	log.Printf("restarting frps in %s", delay)
+	m.supervisor.schedule(delay, m.autoRestart)
+}
+
+func (m *ProcessManager) autoRestart(gen int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if gen != m.supervisor.timerGen || m.running {
+		return
+	}
+	m.supervisor.timer = nil
+	m.supervisor.nextRestart = time.Time{}
+	m.supervisor.restarts++
+
+	if err := m.startLocked(); err != nil {
+		now := time.Now()
+		m.supervisor.record(FrpsCrash{Time: now, ExitCode: -1, Uptime: "0s", Stderr: []string{err.Error()}})
+		log.Printf("frps restart failed: %v", err)
+		m.scheduleRestartLocked(now, 0)
+	}
+}
+
+// ============= Detach 模式：输出文件与 PID 文件 =============
+
+// outputPath 独立进程组模式下 frps 输出写入的文件
+func (m *ProcessManager) outputPath(stream string) string {
+	dir := m.opts.LogDir
+	if dir == "" {
+		dir = filepath.Dir(m.opts.PIDFile)
+	}
+	return filepath.Join(dir, "frps."+stream+".log")
+}
+
+// openFrpsOutput 每次启动清空输出文件，内容已经由 tailOutput 采集到日志中
+func openFrpsOutput(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("create output dir failed: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open frps output failed: %v", err)
+	}
+	return f, nil
+}
+
+// tailOutput 持续读取输出文件的新内容，进程退出后读完剩余内容再返回
+func (m *ProcessManager) tailOutput(path string, fromEnd, stderr bool, exited <-chan struct{}) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("tail frps output failed: %v", err)
+		return
+	}
+	defer f.Close()
+	if fromEnd {
+		f.Seek(0, 2)
+	}
+
+	reader := bufio.NewReader(f)
+	partial := ""
+	draining := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err == nil {
+			m.addLogLine(strings.TrimRight(partial+line, "\r\n"), stderr)
+			partial = ""
+			continue
+		}
+		partial += line
+		if draining {
+			if partial != "" {
+				m.addLogLine(partial, stderr)
+			}
+			return
+		}
+		select {
+		case <-exited:
+			draining = true
+		case <-time.After(outputPollInterval):
+		}
+	}
+}
+
+func (m *ProcessManager) writePIDFile(pid int) {
+	if err := os.WriteFile(m.opts.PIDFile, []byte(strconv.Itoa(pid)+"\n"), 0600); err != nil {
+		log.Printf("write frps pid file failed: %v", err)
+	}
+}
+
+// adopt 在 frp-admin 启动时根据 PID 文件接管仍在运行的 frps
+func (m *ProcessManager) adopt() {
+	data, err := os.ReadFile(m.opts.PIDFile)
+	if err != nil {
+		return
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 || syscall.Kill(pid, 0) != nil {
+		os.Remove(m.opts.PIDFile)
+		return
+	}
+	// PID 可能已被其他进程复用，能读取 /proc 时确认是 frps
+	if cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		if !strings.Contains(string(cmdline), filepath.Base(m.frpsPath)) {
+			log.Printf("pid %d in %s is not frps, ignored", pid, m.opts.PIDFile)
+			os.Remove(m.opts.PIDFile)
+			return
+		}
+	}
+
+	startTime := time.Now()
+	if info, err := os.Stat(m.opts.PIDFile); err == nil {
+		startTime = info.ModTime()
+	}
+	run := &frpsRun{pid: pid, startTime: startTime, adopted: true, done: make(chan struct{})}
+	m.run = run
+	m.running = true
+	log.Printf("adopted running frps (pid %d)", pid)
+
+	exited := make(chan struct{})
+	var readers sync.WaitGroup
+	for _, stream := range []string{"stdout", "stderr"} {
+		readers.Add(1)
+		go func(stream string) {
+			defer readers.Done()
+			m.tailOutput(m.outputPath(stream), true, stream == "stderr", exited)
+		}(stream)
+	}
+	go func() {
+		ticker := time.NewTicker(adoptedPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if syscall.Kill(pid, 0) != nil {
+				break
+			}
+		}
+		close(exited)
+		readers.Wait()
+		m.handleExit(run, -1, "")
+	}()
+}
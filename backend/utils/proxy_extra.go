@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// proxyExtraConfigKeys 代理额外配置中允许使用的 frpc 配置项及其类型
+// map 表示任意字符串键值对，如 metadatas = { owner = "ops" }
+var proxyExtraConfigKeys = map[string]string{
+	"metadatas":                         "map",
+	"annotations":                       "map",
+	"loadBalancer.group":                "string",
+	"loadBalancer.groupKey":             "string",
+	"transport.proxyProtocolVersion":    "string",
+	"transport.useEncryption":           "bool",
+	"transport.useCompression":          "bool",
+	"transport.bandwidthLimit":          "string",
+	"transport.bandwidthLimitMode":      "string",
+	"healthCheck.type":                  "string",
+	"healthCheck.timeoutSeconds":        "int",
+	"healthCheck.maxFailed":             "int",
+	"healthCheck.intervalSeconds":       "int",
+	"healthCheck.path":                  "string",
+	"requestHeaders.set":                "map",
+	"responseHeaders.set":               "map",
+	"natTraversal.disableAssistedAddrs": "bool",
+	"localIP":                           "string",
+	"localPort":                         "int",
+}
+
+// ignoredProxyExtraConfigKeys 早期版本允许写在额外配置中的键，仍然接受以兼容已保存的代理，
+// 但不再输出到 frpc 配置：负载均衡由负载均衡组管理，frps 插件按代理所属的组校验
+var ignoredProxyExtraConfigKeys = map[string]bool{
+	"loadBalancer.group":    true,
+	"loadBalancer.groupKey": true,
+}
+
+// ParseProxyExtraConfig 解析代理的额外配置 JSON，并按白名单校验键和值类型
+// 支持点分键 {"transport.useEncryption": true} 和嵌套对象 {"transport": {"useEncryption": true}} 两种写法
+// loadBalancer.group/groupKey 仍可解析，但不会出现在返回结果中，见 ignoredProxyExtraConfigKeys
+func ParseProxyExtraConfig(raw string) (map[string]interface{}, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var extra map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &extra); err != nil {
+		return nil, fmt.Errorf("额外配置格式错误，应为 JSON 对象: %v", err)
+	}
+
+	result := map[string]interface{}{}
+	var unknown, mismatched []string
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			kind, ok := proxyExtraConfigKeys[key]
+			if !ok {
+				if sub, isMap := v.(map[string]interface{}); isMap {
+					walk(key, sub)
+				} else {
+					unknown = append(unknown, key)
+				}
+				continue
+			}
+			if !matchExtraConfigKind(kind, v) {
+				mismatched = append(mismatched, fmt.Sprintf("%s（应为 %s）", key, kind))
+				continue
+			}
+			if !ignoredProxyExtraConfigKeys[key] {
+				result[key] = v
+			}
+		}
+	}
+	walk("", extra)
+
+	var errs []string
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		errs = append(errs, "不支持的配置项: "+strings.Join(unknown, ", "))
+	}
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		errs = append(errs, "类型错误: "+strings.Join(mismatched, ", "))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("额外配置无效，%s", strings.Join(errs, "；"))
+	}
+	return result, nil
+}
+
+func matchExtraConfigKind(kind string, v interface{}) bool {
+	switch kind {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "bool":
+		_, ok := v.(bool)
+		return ok
+	case "int":
+		return isJSONInteger(v)
+	case "map":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for _, item := range m {
+			if _, ok := item.(string); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,467 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// TomlEditor 基于行的 TOML 文档编辑器
+// 只修改目标键所在的行，其余内容（注释、空行、顺序）原样保留
+type TomlEditor struct {
+	lines []string
+}
+
+// tomlEntry 文档中的一个键值对
+type tomlEntry struct {
+	key       string // 完整键名，如 webServer.port
+	localKey  string // 行内写法，如 port 或 webServer.port
+	table     string // 所在表名，根表为空
+	start     int    // 起始行
+	end       int    // 结束行（多行数组/内联表）
+	indent    string
+	comment   string // 单行值的行尾注释
+	multiLine bool
+	element   int // 所在数组表元素的序号，普通表为 -1
+}
+
+var tomlTableHeaderRe = regexp.MustCompile(`^\s*\[\s*([^\[\]]+?)\s*\]\s*(#.*)?$`)
+var tomlArrayTableRe = regexp.MustCompile(`^\s*\[\[\s*([^\[\]]+?)\s*\]\]\s*(#.*)?$`)
+
+// NewTomlEditor 创建编辑器
+func NewTomlEditor(content string) *TomlEditor {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	return &TomlEditor{lines: strings.Split(content, "\n")}
+}
+
+// String 返回编辑后的内容
+func (e *TomlEditor) String() string {
+	return strings.Join(e.lines, "\n")
+}
+
+// scan 扫描文档中所有普通表（非数组表）下的键值对
+func (e *TomlEditor) scan() []tomlEntry {
+	var entries []tomlEntry
+	for _, entry := range e.scanAll() {
+		if entry.element < 0 {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// scanAll 扫描文档中所有键值对，包括数组表元素中的键值对
+func (e *TomlEditor) scanAll() []tomlEntry {
+	var entries []tomlEntry
+	table := ""
+	element := -1
+	elements := 0
+	inString := e.multilineStringLines()
+
+	for i := 0; i < len(e.lines); i++ {
+		line := e.lines[i]
+		trimmed := strings.TrimSpace(line)
+		if inString[i] || trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if m := tomlArrayTableRe.FindStringSubmatch(line); m != nil {
+			table = normalizeTomlKey(m[1])
+			element = elements
+			elements++
+			continue
+		}
+		if m := tomlTableHeaderRe.FindStringSubmatch(line); m != nil {
+			table = normalizeTomlKey(m[1])
+			element = -1
+			continue
+		}
+
+		eq := findTomlAssign(line)
+		if eq < 0 {
+			continue
+		}
+		localKey := strings.TrimSpace(line[:eq])
+		entry := tomlEntry{
+			localKey: localKey,
+			table:    table,
+			start:    i,
+			end:      i,
+			indent:   line[:len(line)-len(strings.TrimLeft(line, " \t"))],
+			element:  element,
+		}
+		if table != "" {
+			entry.key = table + "." + normalizeTomlKey(localKey)
+		} else {
+			entry.key = normalizeTomlKey(localKey)
+		}
+
+		// 多行字符串：值一直延续到字符串结束的行
+		for entry.end+1 < len(e.lines) && inString[entry.end+1] {
+			entry.end++
+			entry.multiLine = true
+		}
+		if entry.multiLine {
+			i = entry.end
+			entries = append(entries, entry)
+			continue
+		}
+
+		// 多行数组/内联表：一直读到括号平衡
+		depth, comment := tomlValueDepth(line[eq+1:])
+		for depth > 0 && entry.end+1 < len(e.lines) {
+			entry.end++
+			entry.multiLine = true
+			d, c := tomlValueDepth(e.lines[entry.end])
+			depth += d
+			comment = c
+		}
+		if !entry.multiLine {
+			entry.comment = comment
+		}
+		i = entry.end
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// SetInArrayTable 在 [[table]] 中 matchKey 的值等于 matchValue 的元素里设置 key
+// key 已存在时原地替换，否则插入到该元素最后一个键值对之后；没有匹配的元素时返回 false
+func (e *TomlEditor) SetInArrayTable(table, matchKey string, matchValue interface{}, key, literal string) bool {
+	entries := e.scanAll()
+	target := -1
+	for _, entry := range entries {
+		if entry.element < 0 || entry.table != table || entry.key != table+"."+matchKey {
+			continue
+		}
+		var doc map[string]interface{}
+		raw := strings.Join(e.lines[entry.start:entry.end+1], "\n")
+		if err := toml.Unmarshal([]byte(raw), &doc); err != nil {
+			continue
+		}
+		if reflect.DeepEqual(lookupTomlPath(doc, normalizeTomlKey(entry.localKey)), matchValue) {
+			target = entry.element
+			break
+		}
+	}
+	if target < 0 {
+		return false
+	}
+
+	last := -1
+	for _, entry := range entries {
+		if entry.element != target {
+			continue
+		}
+		if entry.key == table+"."+key {
+			newLine := entry.indent + entry.localKey + " = " + literal
+			if entry.comment != "" {
+				newLine += " " + entry.comment
+			}
+			e.replaceLines(entry.start, entry.end, []string{newLine})
+			return true
+		}
+		last = entry.end
+	}
+	e.replaceLines(last+1, last, []string{key + " = " + literal})
+	return true
+}
+
+// Set 设置键的值，literal 为已格式化的 TOML 值
+// 键已存在时原地替换；不存在时追加到对应的表或根表末尾
+func (e *TomlEditor) Set(key, literal string) {
+	entries := e.scan()
+	for _, entry := range entries {
+		if entry.key != key {
+			continue
+		}
+		newLine := entry.indent + entry.localKey + " = " + literal
+		if entry.comment != "" {
+			newLine += " " + entry.comment
+		}
+		e.replaceLines(entry.start, entry.end, []string{newLine})
+		return
+	}
+
+	// 查找是否存在以该键为前缀的表，如 [webServer] 对应 webServer.port
+	bestTable := ""
+	for _, entry := range entries {
+		if entry.table != "" && strings.HasPrefix(key, entry.table+".") && len(entry.table) > len(bestTable) {
+			bestTable = entry.table
+		}
+	}
+	if bestTable == "" {
+		inString := e.multilineStringLines()
+		for i, line := range e.lines {
+			if inString[i] {
+				continue
+			}
+			if m := tomlTableHeaderRe.FindStringSubmatch(line); m != nil && tomlArrayTableRe.FindStringSubmatch(line) == nil {
+				table := normalizeTomlKey(m[1])
+				if strings.HasPrefix(key, table+".") && len(table) > len(bestTable) {
+					bestTable = table
+				}
+			}
+		}
+	}
+
+	if bestTable != "" {
+		localKey := strings.TrimPrefix(key, bestTable+".")
+		e.insertInTable(bestTable, localKey+" = "+literal)
+		return
+	}
+	e.insertInTable("", key+" = "+literal)
+}
+
+// Delete 删除键，键不存在时返回 false
+func (e *TomlEditor) Delete(key string) bool {
+	for _, entry := range e.scan() {
+		if entry.key == key {
+			e.replaceLines(entry.start, entry.end, nil)
+			return true
+		}
+	}
+	return false
+}
+
+// insertInTable 在表的最后一个键值对之后插入一行，找不到键值对时插在表头之后
+func (e *TomlEditor) insertInTable(table, line string) {
+	insertAt := -1
+	headerAt := -1
+	for _, entry := range e.scan() {
+		if entry.table == table {
+			insertAt = entry.end + 1
+		}
+	}
+	if insertAt < 0 {
+		inString := e.multilineStringLines()
+		for i, l := range e.lines {
+			if inString[i] {
+				continue
+			}
+			if table == "" {
+				if tomlTableHeaderRe.MatchString(l) || tomlArrayTableRe.MatchString(l) {
+					headerAt = i
+					break
+				}
+				continue
+			}
+			if m := tomlTableHeaderRe.FindStringSubmatch(l); m != nil && normalizeTomlKey(m[1]) == table {
+				insertAt = i + 1
+				break
+			}
+		}
+	}
+
+	if insertAt < 0 {
+		if headerAt >= 0 {
+			// 根表没有键值对，插入到第一个表头之前并空一行
+			e.replaceLines(headerAt, headerAt-1, []string{line, ""})
+			return
+		}
+		// 没有任何表头，追加到文件末尾（保留末尾换行）
+		n := len(e.lines)
+		if n > 0 && e.lines[n-1] == "" {
+			e.replaceLines(n-1, n-2, []string{line})
+			return
+		}
+		e.lines = append(e.lines, line)
+		return
+	}
+	e.replaceLines(insertAt, insertAt-1, []string{line})
+}
+
+// replaceLines 用 newLines 替换 [start, end] 行，end < start 时为插入
+func (e *TomlEditor) replaceLines(start, end int, newLines []string) {
+	result := make([]string, 0, len(e.lines)+len(newLines))
+	result = append(result, e.lines[:start]...)
+	result = append(result, newLines...)
+	result = append(result, e.lines[end+1:]...)
+	e.lines = result
+}
+
+// multilineStringLines 标记位于多行字符串（三引号）内部的行（不含开始的行），
+// 这些行的内容是字符串值，不能当作键值对或表头
+func (e *TomlEditor) multilineStringLines() []bool {
+	inString := make([]bool, len(e.lines))
+	state := ""
+	for i, line := range e.lines {
+		inString[i] = state != ""
+		state = tomlMultilineState(line, state)
+	}
+	return inString
+}
+
+// tomlMultilineState 从 state（未闭合的多行字符串分隔符，空表示不在字符串中）开始扫描一行，
+// 返回行尾时仍未闭合的分隔符
+func tomlMultilineState(line, state string) string {
+	inQuote := byte(0)
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case state != "":
+			if state == `"""` && ch == '\\' {
+				i++
+			} else if strings.HasPrefix(line[i:], state) {
+				i += len(state) - 1
+				state = ""
+			}
+		case inQuote != 0:
+			if inQuote == '"' && ch == '\\' {
+				i++
+			} else if ch == inQuote {
+				inQuote = 0
+			}
+		case strings.HasPrefix(line[i:], `"""`), strings.HasPrefix(line[i:], "'''"):
+			state = line[i : i+3]
+			i += 2
+		case ch == '"' || ch == '\'':
+			inQuote = ch
+		case ch == '#':
+			return ""
+		}
+	}
+	return state
+}
+
+// findTomlAssign 返回键值对中等号的位置（忽略引号内的等号），不是键值对时返回 -1
+func findTomlAssign(line string) int {
+	inQuote := byte(0)
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case inQuote != 0:
+			if ch == inQuote {
+				inQuote = 0
+			}
+		case ch == '"' || ch == '\'':
+			inQuote = ch
+		case ch == '#':
+			return -1
+		case ch == '=':
+			return i
+		}
+	}
+	return -1
+}
+
+// tomlValueDepth 计算一行值中未闭合的括号层数，同时返回行尾注释
+func tomlValueDepth(s string) (int, string) {
+	depth := 0
+	inQuote := byte(0)
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if inQuote != 0 {
+			if ch == '\\' && inQuote == '"' {
+				i++
+				continue
+			}
+			if ch == inQuote {
+				inQuote = 0
+			}
+			continue
+		}
+		switch ch {
+		case '"', '\'':
+			inQuote = ch
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case '#':
+			return depth, strings.TrimSpace(s[i:])
+		}
+	}
+	return depth, ""
+}
+
+// normalizeTomlKey 去掉键各段两侧的空白和引号，如 `auth . "token"` -> auth.token
+func normalizeTomlKey(key string) string {
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
+
+// FormatTomlLiteral 将 JSON 解码得到的值格式化为 TOML 行内值
+func FormatTomlLiteral(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return QuoteTomlString(val), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case int:
+		return strconv.Itoa(val), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case float64:
+		if val == float64(int64(val)) {
+			return strconv.FormatInt(int64(val), 10), nil
+		}
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			s, err := FormatTomlLiteral(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, 0, len(keys))
+		for _, k := range keys {
+			s, err := FormatTomlLiteral(val[k])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, encodeTomlKey([]string{k})+" = "+s)
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// QuoteTomlString 将字符串转为 TOML 基本字符串，转义引号、反斜杠和控制字符
+func QuoteTomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -2,9 +2,11 @@ package utils
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/pelletier/go-toml/v2"
 )
 
 // FrpcTomlConfig 用于生成frpc配置
@@ -19,6 +21,10 @@ type FrpcTomlConfig struct {
 	AdminUser       string
 	AdminPass       string
 	AdminRemotePort int    // 映射到 frps 的远程端口
+	// 传输、TLS、心跳和日志选项，未设置的项使用内置默认值
+	Options         FrpcClientOptions
+	// 客户端元数据，登录时发送给 frps 插件
+	Metadatas       map[string]string
 	Proxies         []ProxyConfig
 	Visitors        []VisitorConfig
 }
@@ -42,6 +48,17 @@ type ProxyConfig struct {
 	SecretKey   string
 	AllowUsers  string
 
+	// HTTP/HTTPS/TCPMUX 虚拟主机配置
+	CustomDomains     string
+	Subdomain         string
+	Locations         string
+	HostHeaderRewrite string
+	HTTPUser          string
+	HTTPPassword      string
+	RouteByHTTPUser   string
+	RequestHeaders    map[string]string
+	ResponseHeaders   map[string]string
+
 	// 传输选项
 	BandwidthLimit     string
 	BandwidthLimitMode string
@@ -55,6 +72,10 @@ type ProxyConfig struct {
 	HealthCheckIntervalSeconds int
 	HealthCheckPath            string
 
+	// 负载均衡组
+	LoadBalancerGroup    string
+	LoadBalancerGroupKey string
+
 	// 插件配置
 	PluginType   string
 	PluginParams map[string]interface{}
@@ -63,214 +84,480 @@ type ProxyConfig struct {
 }
 
 // GenerateFrpcToml 生成frpc.toml配置文件内容
-func GenerateFrpcToml(config FrpcTomlConfig) string {
-	var buf bytes.Buffer
+// 所有键和值都经过 TOML 编码，用户输入中的引号、换行等字符不会破坏配置结构
+func GenerateFrpcToml(config FrpcTomlConfig) (string, error) {
+	w := &tomlWriter{}
 
 	// 基础配置
-	buf.WriteString("# FRP Client Configuration\n")
-	buf.WriteString("# Generated by frp-admin\n\n")
+	w.Comment("FRP Client Configuration")
+	w.Comment("Generated by frp-admin")
+	w.Blank()
 
 	if config.User != "" {
-		buf.WriteString(fmt.Sprintf("user = \"%s\"\n", config.User))
+		w.KV("user", config.User)
 	}
-	buf.WriteString(fmt.Sprintf("serverAddr = \"%s\"\n", config.ServerAddr))
-	buf.WriteString(fmt.Sprintf("serverPort = %d\n", config.ServerPort))
-	buf.WriteString("\n")
+	w.KV("serverAddr", config.ServerAddr)
+	w.KV("serverPort", config.ServerPort)
+	w.Blank()
+
+	opts := MergeFrpcClientOptions(config.Options, builtinFrpcClientOptions)
 
 	// 日志配置
-	buf.WriteString("log.to = \"/var/log/frpc.log\"\n")
-	buf.WriteString("log.level = \"info\"\n")
-	buf.WriteString("log.maxDays = 3\n")
-	buf.WriteString("\n")
+	w.KV("log.to", opts.LogTo)
+	w.KV("log.level", opts.LogLevel)
+	if opts.LogMaxDays > 0 {
+		w.KV("log.maxDays", opts.LogMaxDays)
+	}
+	w.Blank()
 
 	// 认证配置
-	buf.WriteString("auth.method = \"token\"\n")
-	buf.WriteString(fmt.Sprintf("auth.token = \"%s\"\n", config.AuthToken))
-	buf.WriteString("\n")
+	w.KV("auth.method", "token")
+	w.KV("auth.token", config.AuthToken)
+	for _, k := range sortedKeys(config.Metadatas) {
+		if config.Metadatas[k] != "" {
+			w.KV("metadatas."+k, config.Metadatas[k])
+		}
+	}
+	w.Blank()
 
 	// 连接配置
-	buf.WriteString("# 登录失败不退出，持续重试\n")
-	buf.WriteString("loginFailExit = false\n")
-	buf.WriteString("\n")
+	if !*opts.LoginFailExit {
+		w.Comment("登录失败不退出，持续重试")
+	}
+	w.KV("loginFailExit", *opts.LoginFailExit)
+	w.Blank()
 
+	// 传输配置
+	writeFrpcTransportOptions(w, opts)
 
 	// webServer 配置（用于在线管理）
 	if config.AdminEnabled && config.AdminPort > 0 {
-		buf.WriteString("# 在线管理配置\n")
-		buf.WriteString("webServer.addr = \"127.0.0.1\"\n")
-		buf.WriteString(fmt.Sprintf("webServer.port = %d\n", config.AdminPort))
+		w.Comment("在线管理配置")
+		w.KV("webServer.addr", "127.0.0.1")
+		w.KV("webServer.port", config.AdminPort)
 		if config.AdminUser != "" {
-			buf.WriteString(fmt.Sprintf("webServer.user = \"%s\"\n", config.AdminUser))
+			w.KV("webServer.user", config.AdminUser)
 		}
 		if config.AdminPass != "" {
-			buf.WriteString(fmt.Sprintf("webServer.password = \"%s\"\n", config.AdminPass))
+			w.KV("webServer.password", config.AdminPass)
 		}
-		buf.WriteString("\n")
+		w.Blank()
 	}
 
 	// 自动生成 frpc-admin 代理（用于远程管理）
 	if config.AdminEnabled && config.AdminPort > 0 && config.AdminRemotePort > 0 {
-		buf.WriteString("# 在线管理代理（系统自动生成，请勿删除）\n")
-		buf.WriteString("[[proxies]]\n")
-		buf.WriteString("name = \"frpc-admin\"\n")
-		buf.WriteString("type = \"tcp\"\n")
-		buf.WriteString("localIP = \"127.0.0.1\"\n")
-		buf.WriteString(fmt.Sprintf("localPort = %d\n", config.AdminPort))
-		buf.WriteString(fmt.Sprintf("remotePort = %d\n", config.AdminRemotePort))
-		buf.WriteString("\n")
+		admin := newTomlTable(false)
+		admin.Set("name", "frpc-admin")
+		admin.Set("type", "tcp")
+		admin.Set("localIP", "127.0.0.1")
+		admin.Set("localPort", config.AdminPort)
+		admin.Set("remotePort", config.AdminRemotePort)
+		w.Comment("在线管理代理（系统自动生成，请勿删除）")
+		w.ArrayTable("proxies", admin)
+		w.Blank()
 	}
 
 	// 代理配置
 	for _, proxy := range config.Proxies {
-		buf.WriteString("[[proxies]]\n")
-		buf.WriteString(fmt.Sprintf("name = \"%s\"\n", proxy.Name))
-		buf.WriteString(fmt.Sprintf("type = \"%s\"\n", proxy.Type))
-
-		// 插件模式下不需要 localIP/localPort
-		if proxy.PluginType == "" {
-			if proxy.LocalIP != "" {
-				buf.WriteString(fmt.Sprintf("localIP = \"%s\"\n", proxy.LocalIP))
-			}
-			if proxy.LocalPort > 0 {
-				buf.WriteString(fmt.Sprintf("localPort = %d\n", proxy.LocalPort))
-			}
+		w.ArrayTable("proxies", buildProxyTable(proxy))
+		w.Blank()
+	}
+
+	// 访问者配置
+	for _, visitor := range config.Visitors {
+		t := newTomlTable(false)
+		t.Set("name", visitor.Name)
+		t.Set("type", visitor.Type)
+		t.Set("serverName", visitor.ServerName)
+		if visitor.ServerUser != "" {
+			t.Set("serverUser", visitor.ServerUser)
 		}
+		if visitor.SecretKey != "" {
+			t.Set("secretKey", visitor.SecretKey)
+		}
+		if visitor.BindAddr != "" {
+			t.Set("bindAddr", visitor.BindAddr)
+		}
+		if visitor.BindPort > 0 {
+			t.Set("bindPort", visitor.BindPort)
+		}
+		w.ArrayTable("visitors", t)
+		w.Blank()
+	}
+
+	if w.err != nil {
+		return "", w.err
+	}
+
+	// 最终确认生成的内容可以被正确解析
+	content := w.buf.String()
+	var check map[string]interface{}
+	if err := toml.Unmarshal([]byte(content), &check); err != nil {
+		return "", fmt.Errorf("generated frpc config is invalid: %v", err)
+	}
+	return content, nil
+}
+
+// buildProxyTable 构建单个 [[proxies]] 的内容
+func buildProxyTable(proxy ProxyConfig) *tomlTable {
+	t := newTomlTable(false)
+	t.Set("name", proxy.Name)
+	t.Set("type", proxy.Type)
+
+	// 插件模式下不需要 localIP/localPort
+	if proxy.PluginType == "" {
+		if proxy.LocalIP != "" {
+			t.Set("localIP", proxy.LocalIP)
+		}
+		if proxy.LocalPort > 0 {
+			t.Set("localPort", proxy.LocalPort)
+		}
+	}
 
-		switch proxy.Type {
-		case "tcp", "udp":
-			if proxy.RemotePort >= 0 {
-				buf.WriteString(fmt.Sprintf("remotePort = %d\n", proxy.RemotePort))
+	switch proxy.Type {
+	case "tcp", "udp":
+		if proxy.RemotePort >= 0 {
+			t.Set("remotePort", proxy.RemotePort)
+		}
+	case "stcp", "xtcp", "sudp":
+		if proxy.SecretKey != "" {
+			t.Set("secretKey", proxy.SecretKey)
+		}
+		// 处理 allowUsers，多个用户用逗号分隔
+		allowUsers := splitTomlList(proxy.AllowUsers)
+		if len(allowUsers) == 0 {
+			allowUsers = []string{"*"}
+		}
+		t.Set("allowUsers", allowUsers)
+	case "http", "https":
+		setProxyDomains(t, proxy)
+		// 以下选项仅 http 类型支持
+		if proxy.Type == "http" {
+			if locations := splitTomlList(proxy.Locations); len(locations) > 0 {
+				t.Set("locations", locations)
 			}
-		case "stcp", "xtcp", "sudp":
-			if proxy.SecretKey != "" {
-				buf.WriteString(fmt.Sprintf("secretKey = \"%s\"\n", proxy.SecretKey))
+			if proxy.HostHeaderRewrite != "" {
+				t.Set("hostHeaderRewrite", proxy.HostHeaderRewrite)
 			}
-			// 处理 allowUsers
-			allowUsers := proxy.AllowUsers
-			if allowUsers == "" {
-				allowUsers = "*"
+			setProxyHTTPAuth(t, proxy)
+			for _, k := range sortedKeys(proxy.RequestHeaders) {
+				t.SetPath([]string{"requestHeaders", "set", k}, proxy.RequestHeaders[k])
 			}
-			if allowUsers == "*" {
-				buf.WriteString("allowUsers = [\"*\"]\n")
-			} else {
-				// 多个用户用逗号分隔，转换为数组格式
-				users := strings.Split(allowUsers, ",")
-				var quotedUsers []string
-				for _, u := range users {
-					u = strings.TrimSpace(u)
-					if u != "" {
-						quotedUsers = append(quotedUsers, fmt.Sprintf("\"%s\"", u))
-					}
-				}
-				buf.WriteString(fmt.Sprintf("allowUsers = [%s]\n", strings.Join(quotedUsers, ", ")))
+			for _, k := range sortedKeys(proxy.ResponseHeaders) {
+				t.SetPath([]string{"responseHeaders", "set", k}, proxy.ResponseHeaders[k])
 			}
 		}
+	case "tcpmux":
+		// frps 目前只支持 httpconnect 一种多路复用方式
+		t.Set("multiplexer", "httpconnect")
+		setProxyDomains(t, proxy)
+		setProxyHTTPAuth(t, proxy)
+	}
 
-		// 传输选项
-		if proxy.BandwidthLimit != "" {
-			buf.WriteString(fmt.Sprintf("transport.bandwidthLimit = \"%s\"\n", proxy.BandwidthLimit))
-			if proxy.BandwidthLimitMode != "" {
-				buf.WriteString(fmt.Sprintf("transport.bandwidthLimitMode = \"%s\"\n", proxy.BandwidthLimitMode))
-			}
+	// 传输选项
+	if proxy.BandwidthLimit != "" {
+		t.Set("transport.bandwidthLimit", proxy.BandwidthLimit)
+		if proxy.BandwidthLimitMode != "" {
+			t.Set("transport.bandwidthLimitMode", proxy.BandwidthLimitMode)
+		}
+	}
+	if proxy.UseEncryption {
+		t.Set("transport.useEncryption", true)
+	}
+	if proxy.UseCompression {
+		t.Set("transport.useCompression", true)
+	}
+
+	// 健康检查（udp 不支持）
+	if proxy.HealthCheckType != "" && proxy.Type != "udp" {
+		t.Set("healthCheck.type", proxy.HealthCheckType)
+		if proxy.HealthCheckTimeoutSeconds > 0 {
+			t.Set("healthCheck.timeoutSeconds", proxy.HealthCheckTimeoutSeconds)
+		}
+		if proxy.HealthCheckMaxFailed > 0 {
+			t.Set("healthCheck.maxFailed", proxy.HealthCheckMaxFailed)
 		}
-		if proxy.UseEncryption {
-			buf.WriteString("transport.useEncryption = true\n")
+		if proxy.HealthCheckIntervalSeconds > 0 {
+			t.Set("healthCheck.intervalSeconds", proxy.HealthCheckIntervalSeconds)
 		}
-		if proxy.UseCompression {
-			buf.WriteString("transport.useCompression = true\n")
+		if proxy.HealthCheckType == "http" && proxy.HealthCheckPath != "" {
+			t.Set("healthCheck.path", proxy.HealthCheckPath)
 		}
+	}
 
-		// 健康检查（udp 不支持）
-		if proxy.HealthCheckType != "" && proxy.Type != "udp" {
-			buf.WriteString(fmt.Sprintf("healthCheck.type = \"%s\"\n", proxy.HealthCheckType))
-			if proxy.HealthCheckTimeoutSeconds > 0 {
-				buf.WriteString(fmt.Sprintf("healthCheck.timeoutSeconds = %d\n", proxy.HealthCheckTimeoutSeconds))
-			}
-			if proxy.HealthCheckMaxFailed > 0 {
-				buf.WriteString(fmt.Sprintf("healthCheck.maxFailed = %d\n", proxy.HealthCheckMaxFailed))
-			}
-			if proxy.HealthCheckIntervalSeconds > 0 {
-				buf.WriteString(fmt.Sprintf("healthCheck.intervalSeconds = %d\n", proxy.HealthCheckIntervalSeconds))
-			}
-			if proxy.HealthCheckType == "http" && proxy.HealthCheckPath != "" {
-				buf.WriteString(fmt.Sprintf("healthCheck.path = \"%s\"\n", proxy.HealthCheckPath))
-			}
+	// 额外配置，键可以是点分路径，嵌套对象输出为独立的表
+	for _, k := range sortedKeys(proxy.ExtraConfig) {
+		t.Merge(strings.Split(k, "."), proxy.ExtraConfig[k])
+	}
+
+	// 负载均衡组优先于额外配置中的同名项
+	if proxy.LoadBalancerGroup != "" {
+		t.Set("loadBalancer.group", proxy.LoadBalancerGroup)
+		if proxy.LoadBalancerGroupKey != "" {
+			t.Set("loadBalancer.groupKey", proxy.LoadBalancerGroupKey)
 		}
+	}
 
-		// 插件配置（只有 tcp 支持）
-		if proxy.PluginType != "" && proxy.Type == "tcp" {
-			buf.WriteString(fmt.Sprintf("[proxies.plugin]\n"))
-			buf.WriteString(fmt.Sprintf("type = \"%s\"\n", proxy.PluginType))
-			if proxy.PluginParams != nil {
-				for k, v := range proxy.PluginParams {
-					buf.WriteString(fmt.Sprintf("%s = %s\n", k, formatTomlValue(v)))
-				}
+	// 插件配置（只有 tcp 支持）
+	if proxy.PluginType != "" && proxy.Type == "tcp" {
+		plugin := t.child("plugin", false)
+		plugin.put("type", proxy.PluginType)
+		for _, k := range sortedKeys(proxy.PluginParams) {
+			if k != "type" {
+				plugin.Merge([]string{k}, proxy.PluginParams[k])
 			}
 		}
+	}
 
-		// 额外配置
-		if proxy.ExtraConfig != nil {
-			for k, v := range proxy.ExtraConfig {
-				buf.WriteString(fmt.Sprintf("%s = %s\n", k, formatTomlValue(v)))
-			}
+	return t
+}
+
+func setProxyDomains(t *tomlTable, proxy ProxyConfig) {
+	if domains := splitTomlList(proxy.CustomDomains); len(domains) > 0 {
+		t.Set("customDomains", domains)
+	}
+	if proxy.Subdomain != "" {
+		t.Set("subdomain", proxy.Subdomain)
+	}
+}
+
+// setProxyHTTPAuth 写入 HTTP 基本认证及按用户路由配置（http 和 tcpmux 共用）
+func setProxyHTTPAuth(t *tomlTable, proxy ProxyConfig) {
+	if proxy.HTTPUser != "" {
+		t.Set("httpUser", proxy.HTTPUser)
+	}
+	if proxy.HTTPPassword != "" {
+		t.Set("httpPassword", proxy.HTTPPassword)
+	}
+	if proxy.RouteByHTTPUser != "" {
+		t.Set("routeByHTTPUser", proxy.RouteByHTTPUser)
+	}
+}
+
+// splitTomlList 将逗号分隔的字符串拆分为数组，忽略空项
+func splitTomlList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
 		}
+	}
+	return items
+}
 
-		buf.WriteString("\n")
+// sortedKeys 返回排序后的 map 键，保证生成的配置顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
+	return keys
+}
 
-	// 访问者配置
-	for _, visitor := range config.Visitors {
-		buf.WriteString("[[visitors]]\n")
-		buf.WriteString(fmt.Sprintf("name = \"%s\"\n", visitor.Name))
-		buf.WriteString(fmt.Sprintf("type = \"%s\"\n", visitor.Type))
-		buf.WriteString(fmt.Sprintf("serverName = \"%s\"\n", visitor.ServerName))
-		if visitor.ServerUser != "" {
-			buf.WriteString(fmt.Sprintf("serverUser = \"%s\"\n", visitor.ServerUser))
+// ============= TOML 编码 =============
+
+// tomlTable 保持插入顺序的 TOML 表
+// dotted 为 true 时以点分键（如 transport.useEncryption = true）输出，否则输出为独立的 [表]
+type tomlTable struct {
+	keys   []string
+	values map[string]interface{}
+	dotted bool
+}
+
+func newTomlTable(dotted bool) *tomlTable {
+	return &tomlTable{values: map[string]interface{}{}, dotted: dotted}
+}
+
+// Set 设置值，key 中的点表示嵌套（以点分键形式输出）
+func (t *tomlTable) Set(key string, value interface{}) {
+	t.SetPath(strings.Split(key, "."), value)
+}
+
+// SetPath 按路径设置值，中间层级以点分键形式输出
+func (t *tomlTable) SetPath(path []string, value interface{}) {
+	current := t
+	for _, p := range path[:len(path)-1] {
+		current = current.child(p, true)
+	}
+	current.put(path[len(path)-1], value)
+}
+
+// Merge 按路径合并值，路径中间层级以点分键输出，map 值展开为独立的表
+// （父级为点分表或已存在同名子表时合并进去）
+func (t *tomlTable) Merge(path []string, value interface{}) {
+	current := t
+	for _, p := range path[:len(path)-1] {
+		current = current.child(p, true)
+	}
+	key := path[len(path)-1]
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		current.put(key, value)
+		return
+	}
+	sub := current.child(key, current.dotted)
+	for _, k := range sortedKeys(m) {
+		sub.Merge([]string{k}, m[k])
+	}
+}
+
+// child 获取或创建子表
+func (t *tomlTable) child(key string, dotted bool) *tomlTable {
+	if sub, ok := t.values[key].(*tomlTable); ok {
+		return sub
+	}
+	sub := newTomlTable(dotted)
+	t.put(key, sub)
+	return sub
+}
+
+func (t *tomlTable) put(key string, value interface{}) {
+	if _, exists := t.values[key]; !exists {
+		t.keys = append(t.keys, key)
+	}
+	t.values[key] = value
+}
+
+// tomlWriter 逐行写出 TOML，出错后后续写入忽略，错误保存在 err 中
+type tomlWriter struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (w *tomlWriter) Comment(text string) {
+	w.buf.WriteString("# " + strings.ReplaceAll(text, "\n", "\n# ") + "\n")
+}
+
+func (w *tomlWriter) Blank() {
+	w.buf.WriteString("\n")
+}
+
+// KV 写入一个键值对，key 中的点表示点分键
+func (w *tomlWriter) KV(key string, value interface{}) {
+	w.kv(strings.Split(key, "."), value)
+}
+
+func (w *tomlWriter) kv(path []string, value interface{}) {
+	if w.err != nil {
+		return
+	}
+	encoded, err := encodeTomlValue(value)
+	if err != nil {
+		w.err = fmt.Errorf("encode %s failed: %v", strings.Join(path, "."), err)
+		return
+	}
+	w.buf.WriteString(encodeTomlKey(path) + " = " + encoded + "\n")
+}
+
+// ArrayTable 写入 [[name]] 数组表的一个元素
+func (w *tomlWriter) ArrayTable(name string, t *tomlTable) {
+	w.buf.WriteString("[[" + encodeTomlKey([]string{name}) + "]]\n")
+	w.table([]string{name}, nil, t)
+}
+
+// table 先写出当前表的键值对（包括点分子表），再写出独立的子表
+func (w *tomlWriter) table(path, prefix []string, t *tomlTable) {
+	for _, k := range t.keys {
+		key := append(append([]string{}, prefix...), k)
+		switch v := t.values[k].(type) {
+		case *tomlTable:
+			if v.dotted {
+				w.table(path, key, v)
+			}
+		default:
+			w.kv(key, v)
 		}
-		if visitor.SecretKey != "" {
-			buf.WriteString(fmt.Sprintf("secretKey = \"%s\"\n", visitor.SecretKey))
+	}
+	for _, k := range t.keys {
+		sub, ok := t.values[k].(*tomlTable)
+		if !ok {
+			continue
 		}
-		if visitor.BindAddr != "" {
-			buf.WriteString(fmt.Sprintf("bindAddr = \"%s\"\n", visitor.BindAddr))
+		subPath := append(append(append([]string{}, path...), prefix...), k)
+		if sub.dotted {
+			// 点分子表中可能包含独立的表
+			w.tables(subPath, sub)
+			continue
 		}
-		if visitor.BindPort > 0 {
-			buf.WriteString(fmt.Sprintf("bindPort = %d\n", visitor.BindPort))
+		w.buf.WriteString("[" + encodeTomlKey(subPath) + "]\n")
+		w.table(subPath, nil, sub)
+	}
+}
+
+// tables 只写出点分子表中的独立子表
+func (w *tomlWriter) tables(path []string, t *tomlTable) {
+	for _, k := range t.keys {
+		sub, ok := t.values[k].(*tomlTable)
+		if !ok {
+			continue
+		}
+		subPath := append(append([]string{}, path...), k)
+		if sub.dotted {
+			w.tables(subPath, sub)
+			continue
 		}
-		buf.WriteString("\n")
+		w.buf.WriteString("[" + encodeTomlKey(subPath) + "]\n")
+		w.table(subPath, nil, sub)
 	}
+}
 
-	return buf.String()
+// encodeTomlKey 编码点分键，非裸键字符的部分加引号转义
+func encodeTomlKey(path []string) string {
+	parts := make([]string, len(path))
+	for i, p := range path {
+		if isBareTomlKey(p) {
+			parts[i] = p
+		} else {
+			parts[i] = QuoteTomlString(p)
+		}
+	}
+	return strings.Join(parts, ".")
 }
 
-func formatTomlValue(v interface{}) string {
-	switch val := v.(type) {
-	case string:
-		return fmt.Sprintf("\"%s\"", val)
-	case bool:
-		return fmt.Sprintf("%t", val)
-	case float64:
-		if val == float64(int(val)) {
-			return fmt.Sprintf("%d", int(val))
+func isBareTomlKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !((c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_') {
+			return false
 		}
-		return fmt.Sprintf("%f", val)
-	case int:
-		return fmt.Sprintf("%d", val)
-	default:
-		jsonBytes, _ := json.Marshal(val)
-		return string(jsonBytes)
 	}
+	return true
+}
+
+// encodeTomlValue 使用 TOML 编码器编码单个值，嵌套对象编码为行内表
+func encodeTomlValue(value interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.SetTablesInline(true)
+	if err := enc.Encode(map[string]interface{}{"v": normalizeTomlValue(value)}); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(buf.String(), "v = "), "\n"), nil
 }
 
-// ParseFrpsConfig 解析frps.toml配置文件，提取关键信息
-type FrpsConfigInfo struct {
-	BindAddr       string `json:"bind_addr"`
-	BindPort       int    `json:"bind_port"`
-	AuthToken      string `json:"auth_token"`
-	WebServerAddr  string `json:"web_server_addr"`
-	WebServerPort  int    `json:"web_server_port"`
-	WebServerUser  string `json:"web_server_user"`
-	WebServerPass  string `json:"web_server_pass"`
-	VhostHTTPPort  int    `json:"vhost_http_port"`
-	VhostHTTPSPort int    `json:"vhost_https_port"`
-	SubdomainHost  string `json:"subdomain_host"`
+// normalizeTomlValue 将 JSON 解码得到的整数值（float64）转换为整数
+func normalizeTomlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v)
+		}
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = normalizeTomlValue(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			result[k] = normalizeTomlValue(item)
+		}
+		return result
+	}
+	return value
 }
@@ -1,84 +1,176 @@
 package utils
 
 import (
-	"bufio"
+	"fmt"
 	"os"
-	"strconv"
-	"strings"
+
+	"github.com/pelletier/go-toml/v2"
 )
 
-// FrpsConfig frps.toml 解析后的配置
+// FrpsConfig frps.toml 解析后的配置，字段与 frps 的 TOML 配置项一一对应
 type FrpsConfig struct {
-	BindAddr      string `json:"bind_addr"`
-	BindPort      int    `json:"bind_port"`
-	AuthMethod    string `json:"auth_method"`
-	AuthToken     string `json:"auth_token"`
-	WebServerAddr string `json:"web_server_addr"`
-	WebServerPort int    `json:"web_server_port"`
-	WebServerUser string `json:"web_server_user"`
-	WebServerPass string `json:"web_server_pass"`
+	BindAddr      string `toml:"bindAddr" json:"bind_addr"`
+	BindPort      int    `toml:"bindPort" json:"bind_port"`
+	KCPBindPort   int    `toml:"kcpBindPort" json:"kcp_bind_port"`
+	QUICBindPort  int    `toml:"quicBindPort" json:"quic_bind_port"`
+	ProxyBindAddr string `toml:"proxyBindAddr" json:"proxy_bind_addr"`
+
+	Transport FrpsTransportConfig `toml:"transport" json:"transport"`
+
+	// 虚拟主机配置，端口为 0 表示未启用
+	VhostHTTPPort    int    `toml:"vhostHTTPPort" json:"vhost_http_port"`
+	VhostHTTPSPort   int    `toml:"vhostHTTPSPort" json:"vhost_https_port"`
+	VhostHTTPTimeout int    `toml:"vhostHTTPTimeout" json:"vhost_http_timeout"`
+	SubDomainHost    string `toml:"subDomainHost" json:"subdomain_host"`
+	Custom404Page    string `toml:"custom404Page" json:"custom_404_page"`
+
+	// tcpmux 端口，为 0 表示未启用
+	TCPMuxHTTPConnectPort int  `toml:"tcpmuxHTTPConnectPort" json:"tcpmux_httpconnect_port"`
+	TCPMuxPassthrough     bool `toml:"tcpmuxPassthrough" json:"tcpmux_passthrough"`
+
+	WebServer        FrpsWebServerConfig `toml:"webServer" json:"web_server"`
+	EnablePrometheus bool                `toml:"enablePrometheus" json:"enable_prometheus"`
+	Log              FrpsLogConfig       `toml:"log" json:"log"`
+	Auth             FrpsAuthConfig      `toml:"auth" json:"auth"`
+
+	DetailedErrorsToClient          *bool            `toml:"detailedErrorsToClient" json:"detailed_errors_to_client,omitempty"`
+	UserConnTimeout                 int              `toml:"userConnTimeout" json:"user_conn_timeout"`
+	AllowPorts                      []FrpsPortsRange `toml:"allowPorts" json:"allow_ports"`
+	MaxPortsPerClient               int              `toml:"maxPortsPerClient" json:"max_ports_per_client"`
+	UDPPacketSize                   int              `toml:"udpPacketSize" json:"udp_packet_size"`
+	NatHoleAnalysisDataReserveHours int              `toml:"natholeAnalysisDataReserveHours" json:"nathole_analysis_data_reserve_hours"`
+
+	SSHTunnelGateway FrpsSSHTunnelGateway `toml:"sshTunnelGateway" json:"ssh_tunnel_gateway"`
+	HTTPPlugins      []FrpsHTTPPlugin     `toml:"httpPlugins" json:"http_plugins"`
+}
+
+// FrpsTransportConfig transport.* 配置
+type FrpsTransportConfig struct {
+	TCPMux                  *bool            `toml:"tcpMux" json:"tcp_mux,omitempty"`
+	TCPMuxKeepaliveInterval int              `toml:"tcpMuxKeepaliveInterval" json:"tcp_mux_keepalive_interval"`
+	TCPKeepAlive            int              `toml:"tcpKeepalive" json:"tcp_keepalive"`
+	MaxPoolCount            int              `toml:"maxPoolCount" json:"max_pool_count"`
+	HeartbeatTimeout        int              `toml:"heartbeatTimeout" json:"heartbeat_timeout"`
+	QUIC                    FrpsQUICConfig   `toml:"quic" json:"quic"`
+	TLS                     FrpsTransportTLS `toml:"tls" json:"tls"`
+}
+
+type FrpsQUICConfig struct {
+	KeepalivePeriod    int `toml:"keepalivePeriod" json:"keepalive_period"`
+	MaxIdleTimeout     int `toml:"maxIdleTimeout" json:"max_idle_timeout"`
+	MaxIncomingStreams int `toml:"maxIncomingStreams" json:"max_incoming_streams"`
+}
+
+type FrpsTransportTLS struct {
+	Force         bool   `toml:"force" json:"force"`
+	CertFile      string `toml:"certFile" json:"cert_file"`
+	KeyFile       string `toml:"keyFile" json:"key_file"`
+	TrustedCaFile string `toml:"trustedCaFile" json:"trusted_ca_file"`
+}
+
+// FrpsWebServerConfig webServer.* 配置（dashboard）
+type FrpsWebServerConfig struct {
+	Addr        string `toml:"addr" json:"addr"`
+	Port        int    `toml:"port" json:"port"`
+	User        string `toml:"user" json:"user"`
+	Password    string `toml:"password" json:"password"`
+	AssetsDir   string `toml:"assetsDir" json:"assets_dir"`
+	PprofEnable bool   `toml:"pprofEnable" json:"pprof_enable"`
+	TLS         struct {
+		CertFile string `toml:"certFile" json:"cert_file"`
+		KeyFile  string `toml:"keyFile" json:"key_file"`
+	} `toml:"tls" json:"tls"`
+}
+
+type FrpsLogConfig struct {
+	To                string `toml:"to" json:"to"`
+	Level             string `toml:"level" json:"level"`
+	MaxDays           int    `toml:"maxDays" json:"max_days"`
+	DisablePrintColor bool   `toml:"disablePrintColor" json:"disable_print_color"`
+}
+
+// FrpsAuthConfig auth.* 配置
+type FrpsAuthConfig struct {
+	Method           string   `toml:"method" json:"method"`
+	AdditionalScopes []string `toml:"additionalScopes" json:"additional_scopes"`
+	Token            string   `toml:"token" json:"token"`
+	TokenSource      struct {
+		Type string `toml:"type" json:"type"`
+		File struct {
+			Path string `toml:"path" json:"path"`
+		} `toml:"file" json:"file"`
+	} `toml:"tokenSource" json:"token_source"`
+	OIDC struct {
+		Issuer          string `toml:"issuer" json:"issuer"`
+		Audience        string `toml:"audience" json:"audience"`
+		SkipExpiryCheck bool   `toml:"skipExpiryCheck" json:"skip_expiry_check"`
+		SkipIssuerCheck bool   `toml:"skipIssuerCheck" json:"skip_issuer_check"`
+	} `toml:"oidc" json:"oidc"`
+}
+
+// FrpsPortsRange allowPorts 中的一项，单个端口或端口范围
+type FrpsPortsRange struct {
+	Start  int `toml:"start" json:"start,omitempty"`
+	End    int `toml:"end" json:"end,omitempty"`
+	Single int `toml:"single" json:"single,omitempty"`
+}
+
+type FrpsSSHTunnelGateway struct {
+	BindPort              int    `toml:"bindPort" json:"bind_port"`
+	PrivateKeyFile        string `toml:"privateKeyFile" json:"private_key_file"`
+	AutoGenPrivateKeyPath string `toml:"autoGenPrivateKeyPath" json:"auto_gen_private_key_path"`
+	AuthorizedKeysFile    string `toml:"authorizedKeysFile" json:"authorized_keys_file"`
+}
+
+// FrpsHTTPPlugin [[httpPlugins]] 服务端插件配置
+type FrpsHTTPPlugin struct {
+	Name      string   `toml:"name" json:"name"`
+	Addr      string   `toml:"addr" json:"addr"`
+	Path      string   `toml:"path" json:"path"`
+	Ops       []string `toml:"ops" json:"ops"`
+	TLSVerify bool     `toml:"tlsVerify" json:"tls_verify"`
 }
 
 // ParseFrpsToml 解析 frps.toml 配置文件
 func ParseFrpsToml(filepath string) (*FrpsConfig, error) {
-	file, err := os.Open(filepath)
+	data, err := os.ReadFile(filepath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	return ParseFrpsTomlContent(data)
+}
 
+// ParseFrpsTomlContent 解析 frps TOML 内容，未设置的字段使用 frps 默认值
+func ParseFrpsTomlContent(data []byte) (*FrpsConfig, error) {
 	config := &FrpsConfig{
-		BindAddr:      "0.0.0.0",
-		BindPort:      7000,
-		AuthMethod:    "token",
-		WebServerAddr: "127.0.0.1",
-		WebServerPort: 7500,
-		WebServerUser: "admin",
-		WebServerPass: "admin",
+		BindAddr: "0.0.0.0",
+		BindPort: 7000,
+		Auth: FrpsAuthConfig{
+			Method: "token",
+		},
 	}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// 跳过注释和空行
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		// 解析 key = value
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue
+	if err := toml.Unmarshal(data, config); err != nil {
+		if derr, ok := err.(*toml.DecodeError); ok {
+			row, col := derr.Position()
+			return nil, fmt.Errorf("parse frps config failed at line %d, column %d: %s", row, col, derr.Error())
 		}
+		return nil, fmt.Errorf("parse frps config failed: %v", err)
+	}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		value = strings.Trim(value, "\"")
-
-		switch key {
-		case "bindAddr":
-			config.BindAddr = value
-		case "bindPort":
-			if v, err := strconv.Atoi(value); err == nil {
-				config.BindPort = v
-			}
-		case "auth.method":
-			config.AuthMethod = value
-		case "auth.token":
-			config.AuthToken = value
-		case "webServer.addr":
-			config.WebServerAddr = value
-		case "webServer.port":
-			if v, err := strconv.Atoi(value); err == nil {
-				config.WebServerPort = v
-			}
-		case "webServer.user":
-			config.WebServerUser = value
-		case "webServer.password":
-			config.WebServerPass = value
-		}
+	// dashboard 未配置时沿用原解析器的默认值，避免 dashboard 客户端连接到 0 端口
+	if config.WebServer.Addr == "" {
+		config.WebServer.Addr = "127.0.0.1"
+	}
+	if config.WebServer.Port == 0 {
+		config.WebServer.Port = 7500
+	}
+	if config.WebServer.User == "" {
+		config.WebServer.User = "admin"
+	}
+	if config.WebServer.Password == "" {
+		config.WebServer.Password = "admin"
 	}
 
-	return config, scanner.Err()
+	return config, nil
 }
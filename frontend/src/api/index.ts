@@ -1,5 +1,5 @@
 import axios from 'axios';
-import type { FrpcConfig, Proxy, Visitor, AvailableProxy, FrpsStatus, ServerInfo, ProxyInfo, Settings } from '../types';
+import type { AccessControl, FrpcConfig, Proxy, Visitor, AvailableProxy, FrpsStatus, FrpsCrash, FrpsLifecycleResult, FrpVersion, FrpVersionList, FrpVersionActivateResult, ClientBundleLink, ServerInfo, ProxyInfo, Settings, FrpsConfigVersion, FrpsPluginInfo, FrpsPluginAudit, TokenRotationResult, BulkPushJob, FrpcPreview, FrpsLogPage, FrpsLogEntry, ProxyGroup, ProxyGroupStatus } from '../types';
 
 const api = axios.create({
   baseURL: '/api',
@@ -27,6 +27,46 @@ api.interceptors.response.use(
   }
 );
 
+// 订阅 frps 实时日志（SSE）。EventSource 无法携带 Authorization 头，这里用 fetch 读取事件流，
+// 服务端结束（如 JWT 过期）或连接断开时 Promise 结束
+export async function streamFrpsLogs(
+  params: { level?: string; proxy?: string; user?: string; q?: string },
+  onEntry: (entry: FrpsLogEntry) => void,
+  signal: AbortSignal,
+): Promise<void> {
+  const query = new URLSearchParams(
+    Object.entries(params).filter(([, v]) => v) as [string, string][],
+  ).toString();
+  const res = await fetch(`/api/frps/logs/stream${query ? `?${query}` : ''}`, {
+    headers: { Authorization: `Bearer ${localStorage.getItem('frp_token') || ''}` },
+    signal,
+  });
+  if (!res.ok || !res.body) {
+    throw new Error(`stream failed: ${res.status}`);
+  }
+
+  const reader = res.body.getReader();
+  const decoder = new TextDecoder();
+  let buffer = '';
+  for (;;) {
+    const { done, value } = await reader.read();
+    if (done) return;
+    buffer += decoder.decode(value, { stream: true });
+    let sep;
+    while ((sep = buffer.indexOf('\n\n')) >= 0) {
+      const frame = buffer.slice(0, sep);
+      buffer = buffer.slice(sep + 2);
+      const event = frame.match(/^event:(.*)$/m)?.[1].trim();
+      const data = frame.match(/^data:(.*)$/m)?.[1];
+      if (event === 'log' && data) {
+        onEntry(JSON.parse(data));
+      } else if (event === 'end') {
+        return;
+      }
+    }
+  }
+}
+
 // 认证 API
 export const authApi = {
   login: (username: string, password: string) =>
@@ -38,14 +78,50 @@ export const authApi = {
 // frps 服务管理 API
 export const frpsApi = {
   getStatus: () => api.get<FrpsStatus>('/frps/status'),
+  getCrashes: () => api.get<FrpsCrash[]>('/frps/crashes'),
   start: () => api.post('/frps/start'),
-  stop: () => api.post('/frps/stop'),
-  restart: () => api.post('/frps/restart'),
+  stop: () => api.post<{ message: string; result: FrpsLifecycleResult }>('/frps/stop'),
+  restart: () => api.post<{ message: string; result: FrpsLifecycleResult }>('/frps/restart'),
   getConfig: () => api.get<{ config: string }>('/frps/config'),
   saveConfig: (config: string) => api.post('/frps/config', { config }),
   verifyConfig: (config: string) => api.post('/frps/verify', { config }),
   getLogs: () => api.get<{ logs: string[] }>('/frps/logs'),
+  queryLogs: (params: { level?: string; since?: string; until?: string; q?: string; offset?: number; limit?: number }) =>
+    api.get<FrpsLogPage>('/frps/logs/query', { params }),
   getParsedConfig: () => api.get('/frps/parsed-config'),
+  getSettings: () =>
+    api.get<{ settings: Record<string, unknown>; keys: Record<string, string> }>('/frps/settings'),
+  patchSettings: (changes: Record<string, unknown>) =>
+    api.patch<{ message: string; settings: Record<string, unknown> }>('/frps/settings', changes),
+  // 配置历史
+  getVersions: () => api.get<{ versions: FrpsConfigVersion[] }>('/frps/config/versions'),
+  getVersion: (id: number) => api.get<FrpsConfigVersion>(`/frps/config/versions/${id}`),
+  diffVersions: (from?: number | 'current', to?: number | 'current') =>
+    api.get<{ from: string; to: string; diff: string }>('/frps/config/diff', { params: { from, to } }),
+  rollback: (id: number, restart: boolean) =>
+    api.post(`/frps/config/versions/${id}/rollback`, { restart }),
+  // 服务端插件（客户端独立密钥校验）
+  rotateToken: () => api.post<TokenRotationResult>('/frps/token/rotate'),
+  confirmTokenRotation: (versionId: number) =>
+    api.post('/frps/token/rotate/confirm', { version_id: versionId }),
+  getPlugin: () => api.get<FrpsPluginInfo>('/frps/plugin'),
+  installPlugin: (restart: boolean) => api.post('/frps/plugin/install', { restart }),
+  setPluginMode: (mode: 'enforce' | 'observe') => api.put('/frps/plugin/mode', { mode }),
+  getPluginAudits: (params?: { op?: string; user?: string; limit?: number }) =>
+    api.get<{ audits: FrpsPluginAudit[] }>('/frps/plugin/audits', { params }),
+};
+
+// frp 可执行文件版本 API
+export const frpVersionApi = {
+  list: () => api.get<FrpVersionList>('/frp/versions'),
+  upload: (file: File, sha256?: string) => {
+    const data = new FormData();
+    data.append('file', file);
+    if (sha256) data.append('sha256', sha256);
+    return api.post<FrpVersion>('/frp/versions/upload', data, { timeout: 120000 });
+  },
+  activate: (version: string) => api.post<FrpVersionActivateResult>(`/frp/versions/${version}/activate`),
+  remove: (version: string) => api.delete(`/frp/versions/${version}`),
 };
 
 // frps Dashboard API
@@ -60,11 +136,29 @@ export const clientApi = {
   create: (data: Partial<FrpcConfig>) => api.post<FrpcConfig>('/clients', data),
   update: (id: number, data: Partial<FrpcConfig>) => api.put<FrpcConfig>(`/clients/${id}`, data),
   delete: (id: number) => api.delete(`/clients/${id}`),
+  updateAccess: (id: number, data: AccessControl) => api.put(`/clients/${id}/access`, data),
   download: (id: number) => api.get(`/clients/${id}/download`, { responseType: 'blob' }),
+  // 生成包含 frpc、配置和服务脚本的安装包下载地址（短时有效）
+  createBundle: (id: number, data: { os: string; arch: string; format?: string; version?: string }) =>
+    api.post<ClientBundleLink>(`/clients/${id}/bundle`, data),
+  // 重新生成客户端密钥，旧密钥立即失效
+  rotateSecret: (id: number) =>
+    api.post<{ message: string; pushed: boolean; push_error?: string }>(`/clients/${id}/secret/rotate`),
   // frpc 在线管理
   getFrpcStatus: (id: number) => api.get(`/clients/${id}/frpc/status`),
-  reloadFrpc: (id: number) => api.post(`/clients/${id}/frpc/reload`),
+  reloadFrpc: (id: number, expectedRevision?: string) =>
+    api.post(`/clients/${id}/frpc/reload`, { expected_revision: expectedRevision }),
+  getPreview: (id: number, base: 'live' | 'pushed' = 'live') =>
+    api.get<FrpcPreview>(`/clients/${id}/frpc/preview`, { params: { base } }),
   stopFrpc: (id: number) => api.post(`/clients/${id}/frpc/stop`),
+  getDrift: (id: number) => api.get(`/clients/${id}/frpc/drift`),
+  // 批量推送，后台执行时返回 job_id
+  bulkReload: (data: { client_ids?: number[]; concurrency?: number; background?: boolean }) =>
+    api.post<{ job_id: string; total: number }>('/clients/bulk/reload', data),
+  getJob: (jobId: string) => api.get<BulkPushJob>(`/jobs/${jobId}`),
+  // 配置下发记录
+  getRevisions: (id: number) => api.get(`/clients/${id}/revisions`),
+  getRevision: (revisionId: number) => api.get(`/revisions/${revisionId}`),
 };
 
 // 代理管理 API
@@ -73,6 +167,16 @@ export const proxyApi = {
   create: (clientId: number, data: Partial<Proxy>) => api.post<Proxy>(`/clients/${clientId}/proxies`, data),
   update: (id: number, data: Partial<Proxy>) => api.put<Proxy>(`/proxies/${id}`, data),
   delete: (id: number) => api.delete(`/proxies/${id}`),
+  updateAccess: (id: number, data: AccessControl) => api.put(`/proxies/${id}/access`, data),
+};
+
+// 负载均衡组 API
+export const proxyGroupApi = {
+  list: () => api.get<{ groups: ProxyGroup[] }>('/proxy-groups'),
+  create: (data: Partial<ProxyGroup>) => api.post<ProxyGroup>('/proxy-groups', data),
+  update: (id: number, data: Partial<ProxyGroup>) => api.put<ProxyGroup>(`/proxy-groups/${id}`, data),
+  delete: (id: number) => api.delete(`/proxy-groups/${id}`),
+  getStatus: (id: number) => api.get<ProxyGroupStatus>(`/proxy-groups/${id}/status`),
 };
 
 // 访问者管理 API
@@ -100,6 +204,7 @@ export interface PortUsage {
   proxy_type: string;
   client_name: string;
   client_user: string;
+  group_name?: string;
 }
 
 export interface PortPoolInfo {
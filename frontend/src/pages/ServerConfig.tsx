@@ -1,16 +1,38 @@
 import { useEffect, useState } from 'react';
-import { Card, Button, Space, message, Spin, Input, Tag, Popconfirm, Form, InputNumber, Switch, Tabs, Divider } from 'antd';
+import { Card, Button, Space, message, Spin, Input, Tag, Popconfirm, Form, InputNumber, Switch, Tabs, Divider, Select, DatePicker, Table, Upload } from 'antd';
+import type { Dayjs } from 'dayjs';
 import {
   PlayCircleOutlined,
   PauseCircleOutlined,
   ReloadOutlined,
   SaveOutlined,
   CheckCircleOutlined,
+  UploadOutlined,
 } from '@ant-design/icons';
-import { frpsApi } from '../api';
-import type { FrpsStatus } from '../types';
+import { frpsApi, frpVersionApi, streamFrpsLogs } from '../api';
+import type { FrpsStatus, FrpsLogEntry, FrpsCrash, FrpsLifecycleResult, FrpVersion, FrpVersionList } from '../types';
 
 const { TextArea } = Input;
+const { RangePicker } = DatePicker;
+
+const logLevelColors: Record<string, string> = {
+  trace: 'default',
+  debug: 'default',
+  info: 'blue',
+  warn: 'orange',
+  error: 'red',
+};
+
+// 停止/重启结果的耗时说明，宽限期内未退出时提示已强制结束
+const describeLifecycle = (r?: FrpsLifecycleResult) => {
+  if (!r) return '';
+  const parts = [`停止 ${r.stop_ms}ms`];
+  if (r.escalated) parts[0] += '（超时，已发送 SIGKILL）';
+  if (r.action === 'restart') {
+    parts.push(`等待端口 ${r.port_wait_ms ?? 0}ms`, `启动 ${r.start_ms ?? 0}ms`);
+  }
+  return `（${parts.join('，')}，共 ${r.total_ms}ms）`;
+};
 
 export default function ServerConfig() {
   const [loading, setLoading] = useState(true);
@@ -19,17 +41,101 @@ export default function ServerConfig() {
   const [config, setConfig] = useState('');
   const [logs, setLogs] = useState<string[]>([]);
   const [activeTab, setActiveTab] = useState('form');
+  const [logEntries, setLogEntries] = useState<FrpsLogEntry[]>([]);
+  const [logTotal, setLogTotal] = useState(0);
+  const [logTruncated, setLogTruncated] = useState(false);
+  const [logPage, setLogPage] = useState(1);
+  const [logLevel, setLogLevel] = useState<string | undefined>();
+  const [logRange, setLogRange] = useState<[Dayjs | null, Dayjs | null] | null>(null);
+  const [logSearch, setLogSearch] = useState('');
+  const [logQuerying, setLogQuerying] = useState(false);
+  const [liveLevel, setLiveLevel] = useState<string | undefined>();
+  const [liveUser, setLiveUser] = useState('');
+  const [liveProxy, setLiveProxy] = useState('');
+  const [streaming, setStreaming] = useState(false);
+  const [crashes, setCrashes] = useState<FrpsCrash[]>([]);
+  const [frpVersions, setFrpVersions] = useState<FrpVersionList | null>(null);
+  const [archiveSha256, setArchiveSha256] = useState('');
+  const [uploading, setUploading] = useState(false);
+  const [switchingVersion, setSwitchingVersion] = useState<string | null>(null);
   const [form] = Form.useForm();
 
   const fetchStatus = async () => {
     try {
       const res = await frpsApi.getStatus();
       setStatus(res.data);
+      if (res.data.last_crash) {
+        fetchCrashes();
+      }
     } catch {
       message.error('获取状态失败');
     }
   };
 
+  const fetchFrpVersions = async () => {
+    try {
+      const res = await frpVersionApi.list();
+      setFrpVersions(res.data);
+    } catch {
+      message.error('获取 frp 版本失败');
+    }
+  };
+
+  const handleUploadArchive = async (file: File) => {
+    setUploading(true);
+    try {
+      const res = await frpVersionApi.upload(file, archiveSha256.trim() || undefined);
+      message.success(`已导入 frp ${res.data.version}`);
+      setArchiveSha256('');
+      fetchFrpVersions();
+    } catch (err: unknown) {
+      const error = err as { response?: { data?: { error?: string } } };
+      message.error(error.response?.data?.error || '导入失败');
+    } finally {
+      setUploading(false);
+    }
+  };
+
+  const handleActivateVersion = async (version: string) => {
+    setSwitchingVersion(version);
+    try {
+      const res = await frpVersionApi.activate(version);
+      if (res.data.warning) {
+        message.warning(`${res.data.message}：${res.data.warning}`, 8);
+      } else {
+        message.success(`${res.data.message}${describeLifecycle(res.data.restart)}`);
+      }
+      fetchStatus();
+    } catch (err: unknown) {
+      const error = err as { response?: { data?: { error?: string; rolled_back?: boolean } } };
+      const data = error.response?.data;
+      message.error(`${data?.error || '切换失败'}${data?.rolled_back ? '，已恢复原版本' : ''}`);
+    } finally {
+      setSwitchingVersion(null);
+      fetchFrpVersions();
+    }
+  };
+
+  const handleDeleteVersion = async (version: string) => {
+    try {
+      await frpVersionApi.remove(version);
+      message.success('版本已删除');
+      fetchFrpVersions();
+    } catch (err: unknown) {
+      const error = err as { response?: { data?: { error?: string } } };
+      message.error(error.response?.data?.error || '删除失败');
+    }
+  };
+
+  const fetchCrashes = async () => {
+    try {
+      const res = await frpsApi.getCrashes();
+      setCrashes(res.data);
+    } catch {
+      // 崩溃记录获取失败不影响状态显示
+    }
+  };
+
   const fetchConfig = async () => {
     try {
       const res = await frpsApi.getConfig();
@@ -122,15 +228,69 @@ transport.tls.force = ${values.tlsForce}
     }
   };
 
+  const logPageSize = 50;
+
+  const queryLogs = async (page = 1) => {
+    setLogQuerying(true);
+    try {
+      const res = await frpsApi.queryLogs({
+        level: logLevel,
+        since: logRange?.[0]?.format('YYYY-MM-DD HH:mm:ss'),
+        until: logRange?.[1]?.format('YYYY-MM-DD HH:mm:ss'),
+        q: logSearch || undefined,
+        offset: (page - 1) * logPageSize,
+        limit: logPageSize,
+      });
+      setLogEntries(res.data.entries);
+      setLogTotal(res.data.total);
+      setLogTruncated(res.data.truncated);
+      setLogPage(page);
+    } catch (err: unknown) {
+      const error = err as { response?: { data?: { error?: string } } };
+      message.error(error.response?.data?.error || '查询日志失败');
+    } finally {
+      setLogQuerying(false);
+    }
+  };
+
   useEffect(() => {
-    Promise.all([fetchStatus(), fetchConfig(), fetchLogs()]).finally(() => setLoading(false));
-    const interval = setInterval(() => {
-      fetchStatus();
-      fetchLogs();
-    }, 5000);
+    Promise.all([fetchStatus(), fetchConfig(), fetchFrpVersions()]).finally(() => setLoading(false));
+    const interval = setInterval(fetchStatus, 5000);
     return () => clearInterval(interval);
   }, []);
 
+  // 实时日志：先加载最近的日志，再订阅新日志；订阅失败时退回轮询
+  useEffect(() => {
+    const filtered = liveLevel || liveUser || liveProxy;
+    if (filtered) {
+      setLogs([]);
+    } else {
+      fetchLogs();
+    }
+
+    const controller = new AbortController();
+    let pollTimer: ReturnType<typeof setInterval> | undefined;
+    setStreaming(true);
+    streamFrpsLogs(
+      { level: liveLevel, user: liveUser, proxy: liveProxy },
+      (entry) => setLogs(prev => [...prev.slice(-999), entry.raw]),
+      controller.signal,
+    )
+      .catch(() => {
+        if (!controller.signal.aborted && !filtered) {
+          pollTimer = setInterval(fetchLogs, 5000);
+        }
+      })
+      .finally(() => {
+        if (!controller.signal.aborted) setStreaming(false);
+      });
+
+    return () => {
+      controller.abort();
+      if (pollTimer) clearInterval(pollTimer);
+    };
+  }, [liveLevel, liveUser, liveProxy]);
+
   const handleStart = async () => {
     try {
       await frpsApi.start();
@@ -144,8 +304,8 @@ transport.tls.force = ${values.tlsForce}
 
   const handleStop = async () => {
     try {
-      await frpsApi.stop();
-      message.success('已停止');
+      const res = await frpsApi.stop();
+      message.success(`已停止${describeLifecycle(res.data.result)}`);
       fetchStatus();
     } catch (err: unknown) {
       const error = err as { response?: { data?: { error?: string } } };
@@ -155,8 +315,8 @@ transport.tls.force = ${values.tlsForce}
 
   const handleRestart = async () => {
     try {
-      await frpsApi.restart();
-      message.success('重启成功');
+      const res = await frpsApi.restart();
+      message.success(`重启成功${describeLifecycle(res.data.result)}`);
       fetchStatus();
     } catch (err: unknown) {
       const error = err as { response?: { data?: { error?: string } } };
@@ -228,7 +388,40 @@ transport.tls.force = ${values.tlsForce}
           {status?.running && status.uptime && (
             <span style={{ color: '#888' }}>运行时长: {status.uptime}</span>
           )}
+          {status?.supervised && <Tag color="blue">自动重启</Tag>}
+          {status?.adopted && <Tag>已接管 (PID {status.pid})</Tag>}
+          {!!status?.restarts && <Tag color="orange">已自动重启 {status.restarts} 次</Tag>}
+          {status?.crash_loop && <Tag color="red">崩溃循环，已停止自动重启</Tag>}
+          {status?.next_restart && (
+            <span style={{ color: '#888' }}>
+              将于 {new Date(status.next_restart).toLocaleTimeString()} 重启
+            </span>
+          )}
         </Space>
+        {crashes.length > 0 && (
+          <Table<FrpsCrash>
+            style={{ marginTop: 16 }}
+            size="small"
+            rowKey="time"
+            dataSource={crashes}
+            pagination={{ pageSize: 5 }}
+            columns={[
+              { title: '时间', dataIndex: 'time', width: 180, render: (t: string) => new Date(t).toLocaleString() },
+              {
+                title: '退出',
+                width: 140,
+                render: (_, r) => r.signal || (r.exit_code === -1 ? '未知' : `code ${r.exit_code}`),
+              },
+              { title: '运行时长', dataIndex: 'uptime', width: 100 },
+            ]}
+            expandable={{
+              rowExpandable: (r) => r.stderr?.length > 0,
+              expandedRowRender: (r) => (
+                <pre style={{ margin: 0, fontSize: 12, whiteSpace: 'pre-wrap' }}>{r.stderr.join('\n')}</pre>
+              ),
+            }}
+          />
+        )}
         <div style={{ marginTop: 16 }}>
           <Space>
             <Button
@@ -243,7 +436,7 @@ transport.tls.force = ${values.tlsForce}
               <Button
                 danger
                 icon={<PauseCircleOutlined />}
-                disabled={!status?.running}
+                disabled={!status?.running && !status?.next_restart}
               >
                 停止
               </Button>
@@ -259,6 +452,98 @@ transport.tls.force = ${values.tlsForce}
         </div>
       </Card>
 
+      <Card
+        title="frp 版本"
+        style={{ marginBottom: 16 }}
+        extra={
+          <Space>
+            <Input
+              placeholder="压缩包 sha256（可选）"
+              value={archiveSha256}
+              onChange={(e) => setArchiveSha256(e.target.value)}
+              style={{ width: 260 }}
+            />
+            <Upload
+              accept=".tar.gz,.tgz,.zip"
+              showUploadList={false}
+              beforeUpload={(file) => {
+                handleUploadArchive(file);
+                return false;
+              }}
+            >
+              <Button icon={<UploadOutlined />} loading={uploading}>
+                导入 release 压缩包
+              </Button>
+            </Upload>
+          </Space>
+        }
+      >
+        <Space style={{ marginBottom: 12 }} wrap>
+          <span>当前 frps：</span>
+          {frpVersions?.reported_version ? (
+            <Tag color="blue">v{frpVersions.reported_version}</Tag>
+          ) : (
+            <Tag color="error">{frpVersions?.reported_error || '未知'}</Tag>
+          )}
+          <span style={{ color: '#888' }}>{frpVersions?.frps_path}</span>
+        </Space>
+        <Table<FrpVersion>
+          size="small"
+          rowKey="version"
+          dataSource={frpVersions?.versions || []}
+          pagination={false}
+          locale={{ emptyText: '尚未导入任何版本，当前使用配置中的 frps/frpc' }}
+          columns={[
+            {
+              title: '版本',
+              dataIndex: 'version',
+              render: (v: string, r) => (
+                <Space>
+                  v{v}
+                  {r.active && <Tag color="success">使用中</Tag>}
+                </Space>
+              ),
+            },
+            { title: '包含', render: (_, r) => Object.keys(r.checksums).filter((n) => !n.startsWith('clients/')).join(', ') },
+            { title: 'frpc 平台', render: (_, r) => (r.platforms || []).map((p) => <Tag key={p}>{p}</Tag>) },
+            {
+              title: '校验',
+              render: (_, r) =>
+                r.checksum_error ? <Tag color="error" title={r.checksum_error}>校验失败</Tag> : <Tag color="success">通过</Tag>,
+            },
+            { title: '来源', dataIndex: 'source', ellipsis: true },
+            { title: '导入时间', dataIndex: 'imported_at', render: (t: string) => new Date(t).toLocaleString() },
+            {
+              title: '操作',
+              render: (_, r) => (
+                <Space>
+                  <Popconfirm
+                    title={`切换到 v${r.version}？`}
+                    description={status?.running ? '验证当前配置后将重启 frps，启动失败会自动恢复原版本' : '将先验证当前配置'}
+                    onConfirm={() => handleActivateVersion(r.version)}
+                    disabled={r.active || !!r.checksum_error}
+                  >
+                    <Button
+                      size="small"
+                      type="link"
+                      disabled={r.active || !!r.checksum_error}
+                      loading={switchingVersion === r.version}
+                    >
+                      切换
+                    </Button>
+                  </Popconfirm>
+                  <Popconfirm title="确定删除该版本？" onConfirm={() => handleDeleteVersion(r.version)} disabled={r.active}>
+                    <Button size="small" type="link" danger disabled={r.active}>
+                      删除
+                    </Button>
+                  </Popconfirm>
+                </Space>
+              ),
+            },
+          ]}
+        />
+      </Card>
+
       <Card
         title="配置文件 (frps.toml)"
         style={{ marginBottom: 16 }}
@@ -377,7 +662,25 @@ transport.tls.force = ${values.tlsForce}
         />
       </Card>
 
-      <Card title="运行日志">
+      <Card
+        title="运行日志"
+        extra={
+          <Space>
+            <Tag color={streaming ? 'success' : 'default'}>{streaming ? '实时' : '已断开'}</Tag>
+            <Select
+              allowClear
+              size="small"
+              placeholder="最低级别"
+              value={liveLevel}
+              onChange={setLiveLevel}
+              style={{ width: 110 }}
+              options={['trace', 'debug', 'info', 'warn', 'error'].map(l => ({ label: l, value: l }))}
+            />
+            <Input.Search size="small" allowClear placeholder="客户端 user" onSearch={setLiveUser} style={{ width: 140 }} />
+            <Input.Search size="small" allowClear placeholder="代理名称" onSearch={setLiveProxy} style={{ width: 140 }} />
+          </Space>
+        }
+      >
         <div
           style={{
             height: 300,
@@ -397,6 +700,62 @@ transport.tls.force = ${values.tlsForce}
           )}
         </div>
       </Card>
+
+      <Card title="日志查询" style={{ marginTop: 16 }}>
+        <Space wrap style={{ marginBottom: 16 }}>
+          <Select
+            allowClear
+            placeholder="最低级别"
+            value={logLevel}
+            onChange={setLogLevel}
+            style={{ width: 120 }}
+            options={['trace', 'debug', 'info', 'warn', 'error'].map(l => ({ label: l, value: l }))}
+          />
+          <RangePicker showTime value={logRange} onChange={(range) => setLogRange(range)} />
+          <Input.Search
+            allowClear
+            placeholder="搜索内容"
+            value={logSearch}
+            onChange={(e) => setLogSearch(e.target.value)}
+            onSearch={() => queryLogs(1)}
+            style={{ width: 240 }}
+          />
+          <Button type="primary" onClick={() => queryLogs(1)} loading={logQuerying}>查询</Button>
+        </Space>
+        <Table
+          size="small"
+          rowKey={(_, i) => String(i)}
+          loading={logQuerying}
+          dataSource={logEntries}
+          pagination={{
+            current: logPage,
+            pageSize: logPageSize,
+            // 更旧的日志文件未读取时总数只是下限，多留一页以便继续翻页
+            total: logTruncated ? logTotal + logPageSize : logTotal,
+            showTotal: () => (logTruncated ? `至少 ${logTotal} 条` : `共 ${logTotal} 条`),
+            showSizeChanger: false,
+            onChange: (page) => queryLogs(page),
+          }}
+          columns={[
+            {
+              title: '时间',
+              dataIndex: 'time',
+              key: 'time',
+              width: 180,
+              render: (t: string) => (t && !t.startsWith('0001') ? new Date(t).toLocaleString() : '-'),
+            },
+            {
+              title: '级别',
+              dataIndex: 'level',
+              key: 'level',
+              width: 80,
+              render: (level: string) => (level ? <Tag color={logLevelColors[level]}>{level}</Tag> : '-'),
+            },
+            { title: '位置', dataIndex: 'component', key: 'component', width: 200 },
+            { title: '内容', dataIndex: 'message', key: 'message', render: (m: string) => <code>{m}</code> },
+          ]}
+        />
+      </Card>
     </div>
   );
 }
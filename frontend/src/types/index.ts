@@ -9,12 +9,39 @@ export interface FrpcConfig {
   name: string;
   user: string;
   remark: string;
+  // 访问控制
+  disabled: boolean;
+  expires_at?: string | null;
+  access_windows: string; // JSON: [{ days: [1,2,3,4,5], start: '09:00', end: '18:00' }]，按系统设置 access_timezone 的时区判断
   // frpc 在线管理配置
+  // 自动应用：代理/访问变更后自动推送
+  auto_apply: boolean;
+  apply_status: '' | 'pending' | 'applied' | 'failed';
+  apply_error: string;
+  applied_at?: string | null;
   admin_enabled: boolean;
   admin_port: number;
   admin_user: string;
   admin_pass: string;
   admin_remote_port: number;
+  // frpc 传输、TLS、心跳和日志选项，未设置时使用系统默认值
+  transport_protocol?: '' | 'tcp' | 'kcp' | 'quic' | 'websocket' | 'wss';
+  dial_server_timeout?: number;
+  proxy_url?: string;
+  pool_count?: number;
+  tcp_mux?: boolean | null;
+  heartbeat_interval?: number;
+  heartbeat_timeout?: number;
+  tls_enable?: boolean | null;
+  tls_cert_file?: string;
+  tls_key_file?: string;
+  tls_trusted_ca_file?: string;
+  tls_server_name?: string;
+  tls_disable_custom_tls_first_byte?: boolean | null;
+  login_fail_exit?: boolean | null;
+  log_to?: string;
+  log_level?: string;
+  log_max_days?: number;
   created_at: string;
   updated_at: string;
   proxies?: Proxy[];
@@ -25,12 +52,22 @@ export interface Proxy {
   id: number;
   frpc_config_id: number;
   name: string;
-  type: 'tcp' | 'udp' | 'stcp' | 'xtcp' | 'sudp';
+  type: 'tcp' | 'udp' | 'http' | 'https' | 'tcpmux' | 'stcp' | 'xtcp' | 'sudp';
   local_ip: string;
   local_port: number;
   remote_port: number;
   secret_key: string;
   allow_users: string;
+  // HTTP/HTTPS/TCPMUX 虚拟主机
+  custom_domains: string;
+  subdomain: string;
+  locations: string;
+  host_header_rewrite: string;
+  http_user: string;
+  http_password: string;
+  route_by_http_user: string;
+  request_headers: string;
+  response_headers: string;
   // 传输选项
   bandwidth_limit: string;
   bandwidth_limit_mode: string;
@@ -42,6 +79,12 @@ export interface Proxy {
   health_check_max_failed: number;
   health_check_interval_seconds: number;
   health_check_path: string;
+  // 负载均衡组
+  proxy_group_id?: number | null;
+  // 访问控制
+  disabled: boolean;
+  expires_at?: string | null;
+  access_windows: string;
   // 插件配置
   plugin_type: string;
   plugin_params: string;
@@ -50,6 +93,43 @@ export interface Proxy {
   updated_at: string;
 }
 
+export interface AccessControl {
+  disabled: boolean;
+  expires_at?: string | null;
+  access_windows?: string;
+}
+
+export interface ProxyGroup {
+  id: number;
+  name: string;
+  group_key: string;
+  type: 'tcp' | 'http' | 'tcpmux';
+  remote_port: number;
+  remark: string;
+  member_count?: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface ProxyGroupMember {
+  proxy_id: number;
+  proxy_name: string;
+  client_id: number;
+  client_name: string;
+  client_user: string;
+  local_ip: string;
+  local_port: number;
+  status: 'online' | 'offline' | 'unknown' | string;
+  live?: Record<string, unknown> | null;
+}
+
+export interface ProxyGroupStatus {
+  group: ProxyGroup;
+  members: ProxyGroupMember[];
+  online: number;
+  dashboard_error?: string;
+}
+
 export interface Visitor {
   id: number;
   frpc_config_id: number;
@@ -70,11 +150,169 @@ export interface AvailableProxy extends Proxy {
   server_name: string;
 }
 
+export interface FrpsCrash {
+  time: string;
+  exit_code: number; // -1 表示未知
+  signal?: string;
+  uptime: string;
+  stderr: string[];
+}
+
+export interface FrpVersion {
+  version: string;
+  source: string;
+  archive_sha256: string;
+  imported_at: string;
+  checksums: Record<string, string>; // frps/frpc -> sha256
+  platforms?: string[]; // 可下发的 frpc 平台，如 linux_amd64
+  active: boolean;
+  checksum_error?: string;
+}
+
+export interface FrpVersionList {
+  versions: FrpVersion[];
+  active: string;
+  frps_path: string;
+  reported_version?: string;
+  reported_error?: string;
+}
+
+export interface ClientBundleLink {
+  url: string;
+  path: string;
+  filename: string;
+  expires_at: string;
+  curl: string;
+}
+
+export interface FrpVersionActivateResult {
+  message: string;
+  version: string;
+  reported_version: string;
+  previous: string;
+  restart?: FrpsLifecycleResult;
+  warning?: string; // systemctl/docker 模式下运行的 frps 未随之切换
+}
+
+export interface FrpsLifecycleResult {
+  action: 'stop' | 'restart';
+  pid?: number;
+  signal?: string;
+  escalated: boolean;
+  stop_ms: number;
+  port_wait_ms?: number;
+  start_ms?: number;
+  new_pid?: number;
+  total_ms: number;
+}
+
 export interface FrpsStatus {
   running: boolean;
   pid: number;
   start_time: string;
   uptime: string;
+  manager_type: 'process' | 'systemctl' | 'docker';
+  supervised?: boolean;
+  detached?: boolean;
+  adopted?: boolean;
+  restarts?: number;
+  crash_loop?: boolean;
+  next_restart?: string;
+  last_crash?: FrpsCrash;
+}
+
+export interface FrpsConfigVersion {
+  id: number;
+  content?: string;
+  author: string;
+  source: 'initial' | 'save' | 'settings' | 'rollback' | 'plugin' | 'rotate';
+  verified: boolean;
+  verify_error: string;
+  rollback_of?: number;
+  created_at: string;
+}
+
+export interface FrpsPluginInfo {
+  installed: boolean;
+  plugin: { name: string; addr: string; path: string; ops: string[] } | null;
+  ops: string[];
+  missing_ops: string[];
+  mode: 'enforce' | 'observe';
+  snippet: string;
+}
+
+export interface FieldDiff {
+  key: string;
+  from: unknown;
+  to: unknown;
+}
+
+export interface SectionDiff {
+  added: string[];
+  removed: string[];
+  changed: { name: string; fields: FieldDiff[] }[];
+}
+
+export interface FrpcConfigDiff {
+  in_sync: boolean;
+  common: FieldDiff[];
+  proxies: SectionDiff;
+  visitors: SectionDiff;
+}
+
+export interface FrpcPreview {
+  base: 'live' | 'pushed';
+  revision_id: number;
+  diff: FrpcConfigDiff;
+  unified: string;
+  expected_revision: string;
+}
+
+export interface BulkPushResult {
+  id: number;
+  name: string;
+  status: 'success' | 'failed' | 'skipped';
+  error?: string;
+  duration_ms: number;
+}
+
+export interface BulkPushJob {
+  id: string;
+  status: 'running' | 'done';
+  author: string;
+  total: number;
+  done: number;
+  summary: { success: number; failed: number; skipped: number };
+  results: BulkPushResult[];
+  started_at: string;
+  finished_at?: string | null;
+}
+
+export interface TokenRotationClient {
+  id: number;
+  name: string;
+  reason?: string;
+}
+
+export interface TokenRotationResult {
+  message: string;
+  version_id: number;
+  pushed: TokenRotationClient[]; // 配置已推送，仍需重启 frpc 进程
+  manual: TokenRotationClient[];
+  restart_required: boolean;
+}
+
+export interface FrpsPluginAudit {
+  id: number;
+  op: 'Login' | 'NewProxy' | string;
+  user: string;
+  proxy_name: string;
+  proxy_type: string;
+  remote_port: number;
+  client_address: string;
+  reason: string;
+  enforced: boolean;
+  created_at: string;
 }
 
 export interface ServerInfo {
@@ -114,4 +352,40 @@ export interface Settings {
   port_pool_end?: string;
   admin_port_pool_start?: string;
   admin_port_pool_end?: string;
+  // 访问时间段所用的 IANA 时区，留空为服务器本地时区
+  access_timezone?: string;
+  // frpc 默认选项
+  frpc_transport_protocol?: string;
+  frpc_dial_server_timeout?: string;
+  frpc_proxy_url?: string;
+  frpc_pool_count?: string;
+  frpc_tcp_mux?: string;
+  frpc_heartbeat_interval?: string;
+  frpc_heartbeat_timeout?: string;
+  frpc_tls_enable?: string;
+  frpc_tls_cert_file?: string;
+  frpc_tls_key_file?: string;
+  frpc_tls_trusted_ca_file?: string;
+  frpc_tls_server_name?: string;
+  frpc_tls_disable_custom_tls_first_byte?: string;
+  frpc_login_fail_exit?: string;
+  frpc_log_to?: string;
+  frpc_log_level?: string;
+  frpc_log_max_days?: string;
+}
+
+export interface FrpsLogEntry {
+  time: string;
+  level: '' | 'trace' | 'debug' | 'info' | 'warn' | 'error';
+  component: string;
+  message: string;
+  raw: string;
+}
+
+export interface FrpsLogPage {
+  entries: FrpsLogEntry[];
+  total: number;
+  truncated: boolean; // 未读取更旧的日志文件，total 为下限
+  offset: number;
+  limit: number;
 }